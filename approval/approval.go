@@ -0,0 +1,239 @@
+package approval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Request statuses
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusDenied   = "denied"
+	StatusExpired  = "expired"
+)
+
+// Request is a tool execution waiting for (or given) human approval.
+type Request struct {
+	ID        string                 `json:"id"`
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Requester string                 `json:"requester,omitempty"`
+	Status    string                 `json:"status"`
+	CreatedAt time.Time              `json:"created_at"`
+	DecidedAt *time.Time             `json:"decided_at,omitempty"`
+	DecidedBy string                 `json:"decided_by,omitempty"`
+}
+
+// Queue is an out-of-band approval queue stored as one JSON file per
+// request, so the server and the `devtool approvals` CLI can share it.
+type Queue struct {
+	Dir string
+}
+
+func NewQueue(dir string) *Queue {
+	return &Queue{Dir: dir}
+}
+
+// Submit adds a pending request for tool to the queue.
+func (q *Queue) Submit(tool string, args map[string]interface{}, requester string) (*Request, error) {
+	if err := os.MkdirAll(q.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create approval queue: %w", err)
+	}
+
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	req := &Request{
+		ID:        hex.EncodeToString(id),
+		Tool:      tool,
+		Arguments: args,
+		Requester: requester,
+		Status:    StatusPending,
+		CreatedAt: time.Now().UTC(),
+	}
+	return req, q.save(req)
+}
+
+// Get loads a request by ID.
+func (q *Queue) Get(id string) (*Request, error) {
+	if strings.ContainsAny(id, `/\.`) || id == "" {
+		return nil, fmt.Errorf("invalid approval id '%s'", id)
+	}
+
+	data, err := os.ReadFile(q.path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("approval '%s' not found", id)
+		}
+		return nil, err
+	}
+
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("corrupt approval '%s': %w", id, err)
+	}
+	return &req, nil
+}
+
+// List returns all requests, oldest first.
+func (q *Queue) List() ([]*Request, error) {
+	entries, err := os.ReadDir(q.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var reqs []*Request
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		req, err := q.Get(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		reqs = append(reqs, req)
+	}
+
+	sort.Slice(reqs, func(i, j int) bool {
+		return reqs[i].CreatedAt.Before(reqs[j].CreatedAt)
+	})
+	return reqs, nil
+}
+
+// Decide approves or denies a pending request.
+func (q *Queue) Decide(id string, approve bool, by string) (*Request, error) {
+	req, changed, err := q.update(id, func(req *Request) {
+		req.Status = StatusDenied
+		if approve {
+			req.Status = StatusApproved
+		}
+		now := time.Now().UTC()
+		req.DecidedAt = &now
+		req.DecidedBy = by
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return nil, fmt.Errorf("approval '%s' is already %s", id, req.Status)
+	}
+	return req, nil
+}
+
+// Wait polls a request until it is decided. If ctx ends first the request
+// is marked expired, unless a decision came in meanwhile.
+func (q *Queue) Wait(ctx context.Context, id string, interval time.Duration) (*Request, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		req, err := q.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if req.Status != StatusPending {
+			return req, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			req, _, err := q.update(id, func(req *Request) {
+				now := time.Now().UTC()
+				req.Status = StatusExpired
+				req.DecidedAt = &now
+			})
+			return req, err
+		case <-ticker.C:
+		}
+	}
+}
+
+// update applies fn to a pending request and saves it, holding the
+// request's lock so that the server and the CLI never overwrite each
+// other's changes. Decided requests are returned unchanged.
+func (q *Queue) update(id string, fn func(*Request)) (*Request, bool, error) {
+	unlock, err := q.lock(id)
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlock()
+
+	req, err := q.Get(id)
+	if err != nil {
+		return nil, false, err
+	}
+	if req.Status != StatusPending {
+		return req, false, nil
+	}
+	fn(req)
+	return req, true, q.save(req)
+}
+
+// staleLock is the age after which a lock file is considered left behind
+// by a crashed process.
+const staleLock = 10 * time.Second
+
+// lock takes the lock of a request by creating its lock file, waiting for
+// other holders to release it.
+func (q *Queue) lock(id string) (func(), error) {
+	if strings.ContainsAny(id, `/\.`) || id == "" {
+		return nil, fmt.Errorf("invalid approval id '%s'", id)
+	}
+	path := filepath.Join(q.Dir, id+".lock")
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock approval '%s': %w", id, err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (q *Queue) path(id string) string {
+	return filepath.Join(q.Dir, id+".json")
+}
+
+// save writes a request atomically so readers never see partial files.
+func (q *Queue) save(req *Request) error {
+	data, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(q.Dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), q.path(req.ID))
+}
@@ -0,0 +1,87 @@
+package approval
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQueue_ApproveWhileWaiting(t *testing.T) {
+	q := NewQueue(t.TempDir())
+
+	req, err := q.Submit("backup-db", map[string]interface{}{"db_name": "prod"}, "agent")
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		if _, err := q.Decide(req.ID, true, "alice"); err != nil {
+			t.Errorf("Decide failed: %v", err)
+		}
+	}()
+
+	decided, err := q.Wait(context.Background(), req.ID, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if decided.Status != StatusApproved || decided.DecidedBy != "alice" {
+		t.Errorf("Expected approval by alice, got %+v", decided)
+	}
+
+	if _, err := q.Decide(req.ID, false, "bob"); err == nil {
+		t.Error("Expected error deciding an already decided request")
+	}
+}
+
+func TestQueue_WaitExpires(t *testing.T) {
+	q := NewQueue(t.TempDir())
+
+	req, err := q.Submit("deploy", nil, "agent")
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	decided, err := q.Wait(ctx, req.ID, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if decided.Status != StatusExpired {
+		t.Errorf("Expected expired status, got %s", decided.Status)
+	}
+
+	reqs, err := q.List()
+	if err != nil || len(reqs) != 1 || reqs[0].Status != StatusExpired {
+		t.Errorf("Expected one expired request in list, got %v (%v)", reqs, err)
+	}
+}
+
+func TestQueue_ConcurrentDecisions(t *testing.T) {
+	q := NewQueue(t.TempDir())
+
+	req, err := q.Submit("deploy", nil, "agent")
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	results := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func(i int) {
+			_, err := q.Decide(req.ID, i%2 == 0, "someone")
+			results <- err
+		}(i)
+	}
+
+	decided := 0
+	for i := 0; i < 10; i++ {
+		if err := <-results; err == nil {
+			decided++
+		}
+	}
+	if decided != 1 {
+		t.Errorf("Expected exactly one decision to win, got %d", decided)
+	}
+}
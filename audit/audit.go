@@ -0,0 +1,184 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"devtool/config"
+	"devtool/rotate"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is one line of the audit log.
+type Record struct {
+	Time       time.Time              `json:"time"`
+	Identity   string                 `json:"identity"`
+	Transport  string                 `json:"transport"` // "stdio", "tcp", "wizard" or "cli"
+	Session    string                 `json:"session,omitempty"`
+	Kind       string                 `json:"kind"` // "tool" or "workflow"
+	Name       string                 `json:"name"`
+	Arguments  map[string]interface{} `json:"arguments,omitempty"`
+	DurationMs int64                  `json:"duration_ms"`
+	Status     string                 `json:"status"` // "ok", "error" or "denied"
+	ExitCode   *int                   `json:"exit_code,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	OutputHash string                 `json:"output_sha256,omitempty"`
+}
+
+// Record statuses
+const (
+	StatusOK     = "ok"
+	StatusError  = "error"
+	StatusDenied = "denied"
+)
+
+// Argument names redacted by default. Keys are matched as API or private
+// keys only, so that names such as "keyword" stay readable.
+var defaultRedact = []string{"*password*", "*secret*", "*token*", "*api_key*", "*api-key*", "*apikey*", "*private_key*", "*credential*"}
+
+var (
+	mu     sync.Mutex
+	writer *rotate.Writer
+	redact = defaultRedact
+)
+
+// Setup opens the audit log described by cfg. With an empty file auditing
+// is disabled.
+func Setup(cfg config.AuditConfig) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if writer != nil {
+		writer.Close()
+		writer = nil
+	}
+	redact = Patterns(cfg)
+	if cfg.File == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.File), 0755); err != nil {
+		return err
+	}
+	w := rotate.New(cfg.File, cfg.MaxSizeMB, cfg.MaxBackups)
+	// Open eagerly so configuration errors surface at startup
+	if _, err := w.Write(nil); err != nil {
+		return err
+	}
+	writer = w
+	return nil
+}
+
+// Reopen reopens the audit file, e.g. after logrotate moved it away.
+func Reopen() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if writer == nil {
+		return nil
+	}
+	return writer.Reopen()
+}
+
+// Log appends rec to the audit log. Arguments are redacted using params and
+// the configured patterns before writing.
+func Log(rec Record, params []config.Parameter) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if writer == nil {
+		return
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now().UTC()
+	}
+	rec.Arguments = Redact(rec.Arguments, params, redact)
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	writer.Write(append(line, '\n'))
+}
+
+// Patterns returns the argument names redacted under cfg: the defaults and
+// the configured extra patterns.
+func Patterns(cfg config.AuditConfig) []string {
+	return append(append([]string{}, defaultRedact...), cfg.Redact...)
+}
+
+// Redacted returns a copy of args redacted like audit records, for logging
+// them elsewhere.
+func Redacted(args map[string]interface{}, params []config.Parameter) map[string]interface{} {
+	mu.Lock()
+	patterns := redact
+	mu.Unlock()
+	return Redact(args, params, patterns)
+}
+
+// Redact returns a copy of args with sensitive values replaced: parameters
+// marked sensitive and names matching any pattern (case-insensitive), also
+// in nested objects and arrays.
+func Redact(args map[string]interface{}, params []config.Parameter, patterns []string) map[string]interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+
+	out := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		out[k] = redactValue(v, patterns)
+		for _, p := range params {
+			if p.Name == k && p.Sensitive {
+				out[k] = "[REDACTED]"
+			}
+		}
+		if matchesAny(patterns, k) {
+			out[k] = "[REDACTED]"
+		}
+	}
+	return out
+}
+
+// redactValue returns a copy of v with the values of matching keys of
+// nested objects replaced.
+func redactValue(v interface{}, patterns []string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			if matchesAny(patterns, k) {
+				out[k] = "[REDACTED]"
+			} else {
+				out[k] = redactValue(val, patterns)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = redactValue(val, patterns)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Hash returns the hex SHA-256 of a tool's output.
+func Hash(output string) string {
+	sum := sha256.Sum256([]byte(output))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"devtool/config"
+	"path/filepath"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	args := map[string]interface{}{
+		"db_name":     "prod",
+		"DB_PASSWORD": "hunter2",
+		"api_token":   "abc",
+		"note":        "hello",
+	}
+	params := []config.Parameter{{Name: "note", Sensitive: true}}
+
+	out := Redact(args, params, defaultRedact)
+
+	if out["db_name"] != "prod" {
+		t.Errorf("Expected db_name to be kept, got %v", out["db_name"])
+	}
+	for _, k := range []string{"DB_PASSWORD", "api_token", "note"} {
+		if out[k] != "[REDACTED]" {
+			t.Errorf("Expected %s to be redacted, got %v", k, out[k])
+		}
+	}
+	if args["DB_PASSWORD"] != "hunter2" {
+		t.Errorf("Redact must not modify its input")
+	}
+}
+
+func TestRedact_KeyNames(t *testing.T) {
+	args := map[string]interface{}{"api_key": "a", "stripe_apikey": "b", "private_key_pem": "c", "keyword": "go", "monkey": "yes", "hotkey": "F5"}
+
+	out := Redact(args, nil, defaultRedact)
+
+	for _, k := range []string{"api_key", "stripe_apikey", "private_key_pem"} {
+		if out[k] != "[REDACTED]" {
+			t.Errorf("Expected %s to be redacted, got %v", k, out[k])
+		}
+	}
+	for _, k := range []string{"keyword", "monkey", "hotkey"} {
+		if out[k] != args[k] {
+			t.Errorf("Expected %s to be kept, got %v", k, out[k])
+		}
+	}
+}
+
+func TestRedact_Nested(t *testing.T) {
+	args := map[string]interface{}{
+		"request": map[string]interface{}{
+			"user":    "alice",
+			"headers": []interface{}{map[string]interface{}{"X-Api-Key": "abc"}},
+			"auth":    map[string]interface{}{"password": "hunter2"},
+		},
+	}
+
+	out := Redact(args, nil, defaultRedact)
+
+	req := out["request"].(map[string]interface{})
+	if req["user"] != "alice" {
+		t.Errorf("Expected user to be kept, got %v", req["user"])
+	}
+	if h := req["headers"].([]interface{})[0].(map[string]interface{}); h["X-Api-Key"] != "[REDACTED]" {
+		t.Errorf("Expected keys in arrays to be redacted, got %v", h)
+	}
+	if auth := req["auth"].(map[string]interface{}); auth["password"] != "[REDACTED]" {
+		t.Errorf("Expected nested keys to be redacted, got %v", auth)
+	}
+	if args["request"].(map[string]interface{})["auth"].(map[string]interface{})["password"] != "hunter2" {
+		t.Errorf("Redact must not modify its input")
+	}
+}
+
+func TestLogAndQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := Setup(config.AuditConfig{File: path, MaxSizeMB: 1, MaxBackups: 2}); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	defer Setup(config.AuditConfig{})
+
+	Log(Record{Identity: "alice", Kind: "tool", Name: "deploy", Status: StatusOK,
+		Arguments: map[string]interface{}{"secret": "s3cr3t"}}, nil)
+	Log(Record{Identity: "bob", Kind: "tool", Name: "deploy", Status: StatusDenied}, nil)
+	Log(Record{Identity: "alice", Kind: "workflow", Name: "release", Status: StatusError}, nil)
+
+	all, err := Query(path, 2, Filter{})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(all))
+	}
+	if all[0].Arguments["secret"] != "[REDACTED]" {
+		t.Errorf("Expected secret to be redacted on disk, got %v", all[0].Arguments["secret"])
+	}
+	if all[0].Time.IsZero() {
+		t.Errorf("Expected a timestamp")
+	}
+
+	alice, _ := Query(path, 2, Filter{Identity: "alice"})
+	if len(alice) != 2 {
+		t.Errorf("Expected 2 records for alice, got %d", len(alice))
+	}
+	denied, _ := Query(path, 2, Filter{Name: "deploy", Status: StatusDenied})
+	if len(denied) != 1 || denied[0].Identity != "bob" {
+		t.Errorf("Expected bob's denied call, got %+v", denied)
+	}
+	last, _ := Query(path, 2, Filter{Limit: 1})
+	if len(last) != 1 || last[0].Name != "release" {
+		t.Errorf("Expected the most recent record, got %+v", last)
+	}
+}
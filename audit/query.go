@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"bufio"
+	"devtool/rotate"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Filter selects audit records. Zero fields match everything.
+type Filter struct {
+	Name     string
+	Identity string
+	Status   string
+	Since    time.Time
+	Limit    int // Keep only the most recent Limit records
+}
+
+func (f Filter) match(rec Record) bool {
+	return (f.Name == "" || rec.Name == f.Name) &&
+		(f.Identity == "" || rec.Identity == f.Identity) &&
+		(f.Status == "" || rec.Status == f.Status) &&
+		(f.Since.IsZero() || !rec.Time.Before(f.Since))
+}
+
+// Query reads the audit log at path, including rotated files, and returns
+// the matching records oldest first.
+func Query(path string, maxBackups int, filter Filter) ([]Record, error) {
+	files := []string{}
+	for i := maxBackups; i >= 1; i-- {
+		files = append(files, rotate.Backup(path, i))
+	}
+	files = append(files, path)
+
+	var records []Record
+	for _, file := range files {
+		f, err := os.Open(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var rec Record
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				continue // skip partially written lines
+			}
+			if filter.match(rec) {
+				records = append(records, rec)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[len(records)-filter.Limit:]
+	}
+	return records, nil
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -11,12 +15,33 @@ type Parameter struct {
 	Type        string `yaml:"type" json:"type"`
 	Description string `yaml:"description" json:"description"`
 	Required    bool   `yaml:"required" json:"required"`
+	Sensitive   bool   `yaml:"sensitive" json:"sensitive"` // Redacted in the audit log
+
+	Complete *CompleteConfig `yaml:"complete" json:"complete"` // Where to find suggested values
+}
+
+// CompleteConfig suggests values for a parameter: a static list, the lines
+// printed by a shell command, or the values a JSONPath selects from a tool's
+// output.
+type CompleteConfig struct {
+	Values  []string `yaml:"values" json:"values"`
+	Command string   `yaml:"command" json:"command"` // Gets the typed prefix as $PREFIX
+	Tool    string   `yaml:"tool" json:"tool"`
+	Path    string   `yaml:"path" json:"path"` // JSONPath into the tool's output, e.g. $.items[*].name
 }
 
 type ToolConfig struct {
-	Name        string `yaml:"name" json:"name"`
-	Type        string `yaml:"type" json:"type"` // "http" (default) or "shell"
-	Description string `yaml:"description" json:"description"`
+	Name        string   `yaml:"name" json:"name"`
+	Type        string   `yaml:"type" json:"type"` // "http" (default), "shell" or "mcp"
+	Description string   `yaml:"description" json:"description"`
+	Tags        []string `yaml:"tags" json:"tags"` // Used by access policies
+
+	// Human-in-the-loop approval
+	Confirm bool   `yaml:"confirm" json:"confirm"` // Ask a human before every execution
+	Risk    string `yaml:"risk" json:"risk"`       // "low", "medium" or "high"; high implies confirm
+
+	// Behaviour hints for clients; unset hints are inferred
+	Annotations *Annotations `yaml:"annotations" json:"annotations"`
 
 	// HTTP specific
 	URL     string            `yaml:"url" json:"url"`
@@ -24,9 +49,224 @@ type ToolConfig struct {
 	Headers map[string]string `yaml:"headers" json:"headers"`
 
 	// Shell specific
-	Command string `yaml:"command" json:"command"`
+	Command     string            `yaml:"command" json:"command"`
+	Args        []string          `yaml:"args" json:"args"`               // Argv run directly without a shell; supports {{param}} templating
+	Interpreter string            `yaml:"interpreter" json:"interpreter"` // Interpreter for Command: "sh" (default), "bash", "python3", "pwsh", ...
+	WorkDir     string            `yaml:"workdir" json:"workdir"`         // Working directory; supports {{param}} templating
+	Env         map[string]string `yaml:"env" json:"env"`                 // Extra variables; supports ${VAR} and {{param}}
+	InheritEnv  *EnvInheritance   `yaml:"inherit_env" json:"inherit_env"` // false, or a list of variables to pass through (default: all)
+	Stdin       string            `yaml:"stdin" json:"stdin"`             // Data written to stdin; supports {{param}} templating
+
+	SuccessExitCodes []int `yaml:"success_exit_codes" json:"success_exit_codes"` // Exit codes treated as success (default: 0)
+
+	// Sandbox confines the command; "sandbox: true" uses the defaults.
+	Sandbox *SandboxConfig `yaml:"sandbox" json:"sandbox"`
+
+	// Output limits; unset values are inherited from the top-level config.
+	MaxOutputBytes int    `yaml:"max_output_bytes" json:"max_output_bytes"` // Negative disables the limit
+	Truncate       string `yaml:"truncate" json:"truncate"`                 // Part to keep: "head", "tail" or "both" (default)
+	SpillDir       string `yaml:"spill_dir" json:"spill_dir"`               // Directory for the full output of truncated results
 
 	Parameters []Parameter `yaml:"parameters" json:"parameters"`
+
+	// Call quotas, enforced wherever the tool runs
+	RateLimit     *RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
+	MaxConcurrent int              `yaml:"max_concurrent" json:"max_concurrent"` // Simultaneous executions (0 = unlimited)
+
+	// Pagination makes an HTTP tool follow "next" pages and merge the results.
+	Pagination *PaginationConfig `yaml:"pagination" json:"pagination"`
+
+	// Output declares what the tool returns, for MCP content types.
+	Output *OutputConfig `yaml:"output" json:"output"`
+
+	// MCP specific: the upstream server is started with Command or Args
+	// (stdio), or reached at URL (http(s):// or tcp://, tls://).
+	MCP *MCPConfig `yaml:"mcp" json:"mcp"`
+
+	// InputSchema of imported MCP tools, used instead of one built from
+	// Parameters.
+	InputSchema map[string]interface{} `yaml:"-" json:"-"`
+}
+
+// MCPConfig selects the tools imported from an upstream MCP server. Each
+// becomes a tool of its own that inherits the entry's other settings.
+type MCPConfig struct {
+	Tool    string   `yaml:"tool" json:"tool"`       // Import only this tool, under the entry's name
+	Prefix  string   `yaml:"prefix" json:"prefix"`   // Prepended to the names of imported tools
+	Include []string `yaml:"include" json:"include"` // Tool name patterns to import (default: all)
+	Exclude []string `yaml:"exclude" json:"exclude"` // Tool name patterns to skip
+	Token   string   `yaml:"token" json:"token"`     // Bearer token for HTTP and devtool TCP servers; supports ${VAR}
+}
+
+// OutputConfig describes a tool's output. Without it, HTTP tools are typed
+// by the response's Content-Type and shell tools return text.
+type OutputConfig struct {
+	Type     string `yaml:"type" json:"type"` // "text", "json", "image", "audio" or "resource"
+	MimeType string `yaml:"mime" json:"mime"` // e.g. image/png; detected from the content when unset
+}
+
+// RequiresApproval reports whether a human must confirm each execution.
+func (t ToolConfig) RequiresApproval() bool {
+	return t.Confirm || t.Risk == "high"
+}
+
+// Annotations describe how a tool behaves, so that clients can decide e.g.
+// which calls to allow without asking.
+type Annotations struct {
+	Title       string `yaml:"title" json:"title"`             // Human-readable name
+	ReadOnly    *bool  `yaml:"read_only" json:"read_only"`     // Doesn't modify anything
+	Destructive *bool  `yaml:"destructive" json:"destructive"` // May delete or overwrite data
+	Idempotent  *bool  `yaml:"idempotent" json:"idempotent"`   // Repeated calls with the same arguments have no further effect
+	OpenWorld   *bool  `yaml:"open_world" json:"open_world"`   // Reaches systems outside the machine
+}
+
+// Hints are resolved annotations.
+type Hints struct {
+	Title       string
+	ReadOnly    bool
+	Destructive bool
+	Idempotent  bool
+	OpenWorld   bool
+}
+
+// validate rejects annotations that contradict each other.
+func (a *Annotations) validate() error {
+	if a != nil && a.ReadOnly != nil && *a.ReadOnly && a.Destructive != nil && *a.Destructive {
+		return fmt.Errorf("annotations can't be both read_only and destructive")
+	}
+	return nil
+}
+
+// apply overrides hints with those set in a, if any. Read-only tools are
+// never destructive and always idempotent, so marking a tool destructive
+// also makes it writable.
+func (a *Annotations) apply(h Hints) Hints {
+	if a != nil {
+		if a.Title != "" {
+			h.Title = a.Title
+		}
+		if a.ReadOnly != nil {
+			h.ReadOnly = *a.ReadOnly
+		}
+		if a.Destructive != nil {
+			h.Destructive = *a.Destructive
+			if h.Destructive && a.ReadOnly == nil {
+				h.ReadOnly = false
+			}
+		}
+		if a.Idempotent != nil {
+			h.Idempotent = *a.Idempotent
+		}
+		if a.OpenWorld != nil {
+			h.OpenWorld = *a.OpenWorld
+		}
+	}
+	if h.ReadOnly {
+		h.Destructive = false
+		h.Idempotent = true
+	}
+	return h
+}
+
+// Hints returns the tool's annotations, inferring unset ones. HTTP tools
+// follow the semantics of their method: GET, HEAD and OPTIONS are read-only,
+// PUT and DELETE idempotent. Shell tools are assumed to be destructive, as
+// MCP assumes for unannotated tools, and stay in the closed world when
+// sandboxed without network access. MCP tools are annotated by their
+// server, with the same defaults.
+func (t ToolConfig) Hints() Hints {
+	var h Hints
+	switch t.Type {
+	case "shell":
+		h = Hints{Destructive: true, OpenWorld: t.Sandbox == nil || t.Sandbox.Network}
+	case "mcp":
+		h = Hints{Destructive: true, OpenWorld: true}
+	default:
+		switch strings.ToUpper(t.Method) {
+		case "", "GET", "HEAD", "OPTIONS":
+			h = Hints{ReadOnly: true, Idempotent: true, OpenWorld: true}
+		case "PUT", "DELETE":
+			h = Hints{Destructive: true, Idempotent: true, OpenWorld: true}
+		case "POST":
+			h = Hints{OpenWorld: true}
+		default:
+			h = Hints{Destructive: true, OpenWorld: true}
+		}
+	}
+	return t.Annotations.apply(h)
+}
+
+// RateLimitConfig is a token bucket: PerMinute calls are allowed on average,
+// with up to Burst calls at once.
+type RateLimitConfig struct {
+	PerMinute int `yaml:"per_minute" json:"per_minute"`
+	Burst     int `yaml:"burst" json:"burst"` // Default 1
+}
+
+type PaginationConfig struct {
+	Type      string `yaml:"type" json:"type"`             // "link" (default), "cursor" or "page"
+	ItemsPath string `yaml:"items_path" json:"items_path"` // JSONPath of the array to merge, e.g. $.items (default: $)
+	NextPath  string `yaml:"next_path" json:"next_path"`   // JSONPath of the next URL ("link") or cursor ("cursor")
+	Param     string `yaml:"param" json:"param"`           // Argument carrying the cursor or page number
+	StartPage int    `yaml:"start_page" json:"start_page"` // First page number for "page" (default 1)
+	PageSize  int    `yaml:"page_size" json:"page_size"`   // Items in a full page for "page"; a shorter page is the last
+	MaxPages  int    `yaml:"max_pages" json:"max_pages"`   // Upper bound on requests made (default 10)
+}
+
+// EnvInheritance controls which variables of the server environment a shell
+// tool sees. In YAML it is either a bool (everything or nothing) or a list of
+// variable names to pass through.
+type EnvInheritance struct {
+	All   bool
+	Allow []string
+}
+
+func (e *EnvInheritance) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var all bool
+		if err := value.Decode(&all); err != nil {
+			return fmt.Errorf("inherit_env must be a bool or a list of names: %w", err)
+		}
+		*e = EnvInheritance{All: all}
+		return nil
+	}
+
+	var allow []string
+	if err := value.Decode(&allow); err != nil {
+		return fmt.Errorf("inherit_env must be a bool or a list of names: %w", err)
+	}
+	*e = EnvInheritance{Allow: allow}
+	return nil
+}
+
+// SandboxConfig runs a shell tool inside a bubblewrap sandbox. The file
+// system is read-only apart from a private /tmp and the Writable paths, and
+// the network is unshared unless Network is set.
+type SandboxConfig struct {
+	Wrapper    string   `yaml:"wrapper" json:"wrapper"`         // bubblewrap-compatible binary (default "bwrap")
+	Readable   []string `yaml:"readable" json:"readable"`       // Paths in the home directory mounted read-only
+	Writable   []string `yaml:"writable" json:"writable"`       // Paths mounted read-write
+	Network    bool     `yaml:"network" json:"network"`         // Allow network access
+	CPUSeconds int      `yaml:"cpu_seconds" json:"cpu_seconds"` // CPU time limit
+	MemoryMB   int      `yaml:"memory_mb" json:"memory_mb"`     // Address space limit
+	MaxProcs   int      `yaml:"max_procs" json:"max_procs"`     // Process count limit
+}
+
+func (sb *SandboxConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var enabled bool
+		if err := value.Decode(&enabled); err != nil {
+			return fmt.Errorf("sandbox must be a bool or a mapping: %w", err)
+		}
+		if !enabled {
+			return fmt.Errorf("sandbox: false is not supported; remove the setting instead")
+		}
+		*sb = SandboxConfig{}
+		return nil
+	}
+
+	type plain SandboxConfig
+	return value.Decode((*plain)(sb))
 }
 
 type StepConfig struct {
@@ -38,20 +278,193 @@ type StepConfig struct {
 type WorkflowConfig struct {
 	Name        string       `yaml:"name" json:"name"`
 	Description string       `yaml:"description" json:"description"`
+	Tags        []string     `yaml:"tags" json:"tags"`
+	Confirm     bool         `yaml:"confirm" json:"confirm"`
+	Risk        string       `yaml:"risk" json:"risk"`
+	Annotations *Annotations `yaml:"annotations" json:"annotations"` // Unset hints are aggregated from the steps
 	Parameters  []Parameter  `yaml:"parameters" json:"parameters"`
 	Steps       []StepConfig `yaml:"steps" json:"steps"`
 	Output      string       `yaml:"output" json:"output"` // Output template
+
+	MaxOutputBytes int `yaml:"max_output_bytes" json:"max_output_bytes"` // Negative disables the limit
+}
+
+// RequiresApproval reports whether a human must confirm the workflow, either
+// because it is marked itself or because one of its steps is.
+func (w WorkflowConfig) RequiresApproval(tools []ToolConfig) bool {
+	if w.Confirm || w.Risk == "high" {
+		return true
+	}
+	for _, step := range w.Steps {
+		for _, t := range tools {
+			if t.Name == step.Tool && t.RequiresApproval() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Hints aggregates the hints of the workflow's steps: it is read-only and
+// idempotent if all steps are, and destructive or open-world if any step is.
+// Steps naming unknown tools are assumed to be destructive.
+func (w WorkflowConfig) Hints(tools []ToolConfig) Hints {
+	h := Hints{ReadOnly: true, Idempotent: true}
+	for _, step := range w.Steps {
+		sh := Hints{Destructive: true, OpenWorld: true}
+		for _, t := range tools {
+			if t.Name == step.Tool {
+				sh = t.Hints()
+				break
+			}
+		}
+		h.ReadOnly = h.ReadOnly && sh.ReadOnly
+		h.Idempotent = h.Idempotent && sh.Idempotent
+		h.Destructive = h.Destructive || sh.Destructive
+		h.OpenWorld = h.OpenWorld || sh.OpenWorld
+	}
+	return w.Annotations.apply(h)
+}
+
+// ResourceConfig exposes read-only context to MCP clients. Exactly one of
+// File, Glob or Command must be set.
+type ResourceConfig struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	MimeType    string `yaml:"mime_type" json:"mime_type"`
+	URI         string `yaml:"uri" json:"uri"`                   // Defaults to file:// for files, devtool://resources/<name> for commands
+	URITemplate string `yaml:"uri_template" json:"uri_template"` // Command resources only, e.g. git://commits/{sha}
+
+	File    string        `yaml:"file" json:"file"`       // A single file
+	Glob    string        `yaml:"glob" json:"glob"`       // One resource per matching file
+	Command string        `yaml:"command" json:"command"` // Output of a shell command; template variables are exported like tool arguments
+	WorkDir string        `yaml:"workdir" json:"workdir"` // Working directory of Command
+	Timeout time.Duration `yaml:"timeout" json:"timeout"` // How long Command may run (default 30s)
+}
+
+// DefaultResourceTimeout bounds command resources without a timeout.
+const DefaultResourceTimeout = 30 * time.Second
+
+// PromptConfig is a reusable prompt template offered to MCP clients.
+type PromptConfig struct {
+	Name        string          `yaml:"name" json:"name"`
+	Description string          `yaml:"description" json:"description"`
+	Arguments   []Parameter     `yaml:"arguments" json:"arguments"`
+	Messages    []PromptMessage `yaml:"messages" json:"messages"`
+}
+
+// PromptMessage is one message of a prompt. Its content is Text, the
+// contents of Resource, or the output of Tool run with Args. All string
+// values may use {{argument}} placeholders.
+type PromptMessage struct {
+	Role     string                 `yaml:"role" json:"role"` // user (default) or assistant
+	Text     string                 `yaml:"text" json:"text"`
+	Resource string                 `yaml:"resource" json:"resource"` // Resource URI to embed
+	Tool     string                 `yaml:"tool" json:"tool"`         // Tool or workflow whose output to embed
+	Args     map[string]interface{} `yaml:"args" json:"args"`
 }
 
 type ServerConfig struct {
-	Port int `yaml:"port" json:"port"`
+	Port int        `yaml:"port" json:"port"`
+	Bind string     `yaml:"bind" json:"bind"` // Listen address for TCP (default 127.0.0.1)
+	Auth AuthConfig `yaml:"auth" json:"auth"`
+	TLS  *TLSConfig `yaml:"tls" json:"tls"`
+
+	PageSize int      `yaml:"page_size" json:"page_size"` // Tools per tools/list page (default 100, negative disables paging)
+	Toolsets []string `yaml:"toolsets" json:"toolsets"`   // Tags of the tools sessions see unless they select others (default: all)
+}
+
+// AuthConfig lists the bearer tokens accepted by the TCP server. Each token
+// has a name that identifies the caller.
+type AuthConfig struct {
+	Tokens     []TokenConfig `yaml:"tokens" json:"tokens"`
+	TokensFile string        `yaml:"tokens_file" json:"tokens_file"` // One "name:token" per line
+}
+
+type TokenConfig struct {
+	Name  string `yaml:"name" json:"name"`
+	Token string `yaml:"token" json:"token"` // Supports ${VAR}
+}
+
+// Enabled reports whether clients must present a token.
+func (a AuthConfig) Enabled() bool {
+	return len(a.Tokens) > 0 || a.TokensFile != ""
+}
+
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file" json:"cert_file"`
+	KeyFile      string `yaml:"key_file" json:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file" json:"client_ca_file"` // Require client certificates signed by this CA (mutual TLS)
+}
+
+// DefaultMaxOutputBytes bounds tool output when max_output_bytes is not set.
+const DefaultMaxOutputBytes = 256 * 1024
+
+// ApprovalConfig configures the out-of-band approval queue used when an MCP
+// client can't ask its user directly.
+type ApprovalConfig struct {
+	Dir     string        `yaml:"dir" json:"dir"`         // Queue directory, relative to the config file (default ".devtool/approvals")
+	Timeout time.Duration `yaml:"timeout" json:"timeout"` // How long to wait for a decision (default 5m)
+}
+
+// LoggingConfig controls the server log.
+type LoggingConfig struct {
+	Level  string `yaml:"level" json:"level"`   // debug, info (default), warn or error
+	Format string `yaml:"format" json:"format"` // text (default) or json
+
+	MaxSizeMB   int           `yaml:"max_size_mb" json:"max_size_mb"`   // Rotate the log file after this size (default 100)
+	RotateEvery time.Duration `yaml:"rotate_every" json:"rotate_every"` // Also rotate at the start of every interval, e.g. 24h
+	MaxBackups  int           `yaml:"max_backups" json:"max_backups"`   // Rotated files to keep (default 5)
+	MaxAgeDays  int           `yaml:"max_age_days" json:"max_age_days"` // Delete rotated files older than this; 0 keeps them
+	Compress    bool          `yaml:"compress" json:"compress"`         // Gzip rotated files
+}
+
+// MetricsConfig enables the Prometheus metrics endpoint.
+type MetricsConfig struct {
+	Addr string `yaml:"addr" json:"addr"` // Listen address, e.g. 127.0.0.1:9464; empty disables metrics
+	Path string `yaml:"path" json:"path"` // HTTP path (default /metrics)
+}
+
+// TracingConfig enables OpenTelemetry tracing.
+type TracingConfig struct {
+	Exporter    string            `yaml:"exporter" json:"exporter"`         // otlp, file, stdout or stderr; empty disables tracing
+	Endpoint    string            `yaml:"endpoint" json:"endpoint"`         // OTLP/HTTP collector URL (default http://localhost:4318)
+	Headers     map[string]string `yaml:"headers" json:"headers"`           // Extra OTLP headers; supports ${ENV_VAR}
+	File        string            `yaml:"file" json:"file"`                 // Output file for the file exporter
+	ServiceName string            `yaml:"service_name" json:"service_name"` // Reported service.name (default devtool)
+}
+
+// AuditConfig enables the append-only audit log of tool executions.
+type AuditConfig struct {
+	File       string   `yaml:"file" json:"file"`               // JSON lines file; empty disables auditing
+	MaxSizeMB  int      `yaml:"max_size_mb" json:"max_size_mb"` // Rotate after this size (default 100)
+	MaxBackups int      `yaml:"max_backups" json:"max_backups"` // Rotated files to keep (default 10)
+	Redact     []string `yaml:"redact" json:"redact"`           // Extra argument name patterns to redact
+}
+
+// PolicyConfig grants the matching identities access to tools and
+// workflows. When no policies are configured everything is allowed.
+type PolicyConfig struct {
+	Identities []string `yaml:"identities" json:"identities"` // Token names or client certificate CNs ("local" for stdio); patterns like "ci-*" or "*"
+	Allow      []string `yaml:"allow" json:"allow"`           // Tool/workflow name patterns
+	Tags       []string `yaml:"tags" json:"tags"`             // Tools/workflows with any of these tags
 }
 
 type Config struct {
-	LogFile string       `yaml:"logfile" json:"logfile"`
-	Server  ServerConfig `yaml:"server" json:"server"`
-	Tools     []ToolConfig     `yaml:"tools" json:"tools"`
-	Workflows []WorkflowConfig `yaml:"workflows" json:"workflows"`
+	LogFile        string           `yaml:"logfile" json:"logfile"`
+	Logging        LoggingConfig    `yaml:"logging" json:"logging"`
+	MaxOutputBytes int              `yaml:"max_output_bytes" json:"max_output_bytes"` // Default for tools; negative disables the limit
+	SpillDir       string           `yaml:"spill_dir" json:"spill_dir"`               // Default for tools
+	Approvals      ApprovalConfig   `yaml:"approvals" json:"approvals"`
+	Audit          AuditConfig      `yaml:"audit" json:"audit"`
+	Metrics        MetricsConfig    `yaml:"metrics" json:"metrics"`
+	Tracing        TracingConfig    `yaml:"tracing" json:"tracing"`
+	Server         ServerConfig     `yaml:"server" json:"server"`
+	Policies       []PolicyConfig   `yaml:"policies" json:"policies"`
+	Tools          []ToolConfig     `yaml:"tools" json:"tools"`
+	Workflows      []WorkflowConfig `yaml:"workflows" json:"workflows"`
+	Resources      []ResourceConfig `yaml:"resources" json:"resources"`
+	Prompts        []PromptConfig   `yaml:"prompts" json:"prompts"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -66,5 +479,99 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	cfg.applyDefaults()
+	// The approval queue is shared with the CLI, so it must not depend on
+	// the working directory
+	if !filepath.IsAbs(cfg.Approvals.Dir) {
+		cfg.Approvals.Dir = filepath.Join(filepath.Dir(path), cfg.Approvals.Dir)
+	}
 	return &cfg, nil
 }
+
+// validate rejects settings that can't be served.
+func (cfg *Config) validate() error {
+	for _, t := range cfg.Tools {
+		if err := t.Annotations.validate(); err != nil {
+			return fmt.Errorf("tool '%s': %w", t.Name, err)
+		}
+	}
+	for _, w := range cfg.Workflows {
+		if err := w.Annotations.validate(); err != nil {
+			return fmt.Errorf("workflow '%s': %w", w.Name, err)
+		}
+	}
+	for _, r := range cfg.Resources {
+		set := 0
+		for _, v := range []string{r.File, r.Glob, r.Command} {
+			if v != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return fmt.Errorf("resource '%s' must set exactly one of file, glob or command", r.Name)
+		}
+		if r.URITemplate != "" && r.Command == "" {
+			return fmt.Errorf("resource '%s' sets uri_template, which requires command", r.Name)
+		}
+	}
+	return nil
+}
+
+// applyDefaults copies top-level settings into the tools that don't
+// override them.
+func (cfg *Config) applyDefaults() {
+	if cfg.MaxOutputBytes == 0 {
+		cfg.MaxOutputBytes = DefaultMaxOutputBytes
+	}
+	if cfg.Approvals.Dir == "" {
+		cfg.Approvals.Dir = ".devtool/approvals"
+	}
+	if cfg.Logging.MaxSizeMB == 0 {
+		cfg.Logging.MaxSizeMB = 100
+	}
+	if cfg.Logging.MaxBackups == 0 {
+		cfg.Logging.MaxBackups = 5
+	}
+	if cfg.Metrics.Path == "" {
+		cfg.Metrics.Path = "/metrics"
+	}
+	if cfg.Tracing.Endpoint == "" {
+		cfg.Tracing.Endpoint = "http://localhost:4318"
+	}
+	if cfg.Tracing.ServiceName == "" {
+		cfg.Tracing.ServiceName = "devtool"
+	}
+	if cfg.Audit.MaxSizeMB == 0 {
+		cfg.Audit.MaxSizeMB = 100
+	}
+	if cfg.Audit.MaxBackups == 0 {
+		cfg.Audit.MaxBackups = 10
+	}
+	if cfg.Server.Bind == "" {
+		cfg.Server.Bind = "127.0.0.1"
+	}
+	if cfg.Server.PageSize == 0 {
+		cfg.Server.PageSize = 100
+	}
+	if cfg.Approvals.Timeout == 0 {
+		cfg.Approvals.Timeout = 5 * time.Minute
+	}
+
+	for i := range cfg.Tools {
+		t := &cfg.Tools[i]
+		if t.MaxOutputBytes == 0 {
+			t.MaxOutputBytes = cfg.MaxOutputBytes
+		}
+		if t.SpillDir == "" {
+			t.SpillDir = cfg.SpillDir
+		}
+	}
+	for i := range cfg.Workflows {
+		if cfg.Workflows[i].MaxOutputBytes == 0 {
+			cfg.Workflows[i].MaxOutputBytes = cfg.MaxOutputBytes
+		}
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -91,3 +92,182 @@ func TestLoadConfig_InvalidYAML(t *testing.T) {
 		t.Error("Expected error for invalid YAML, got nil")
 	}
 }
+
+func TestLoadConfig_InheritEnv(t *testing.T) {
+	dir := t.TempDir()
+	configContent := `
+tools:
+  - name: "isolated"
+    type: "shell"
+    command: "env"
+    inherit_env: false
+  - name: "allowlist"
+    type: "shell"
+    command: "env"
+    inherit_env: [PATH, HOME]
+  - name: "default"
+    type: "shell"
+    command: "env"
+`
+	configPath := filepath.Join(dir, "devtool.yaml")
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if inherit := cfg.Tools[0].InheritEnv; inherit == nil || inherit.All || len(inherit.Allow) != 0 {
+		t.Errorf("Expected inherit_env false to disable inheritance, got %+v", inherit)
+	}
+	if inherit := cfg.Tools[1].InheritEnv; inherit == nil || len(inherit.Allow) != 2 || inherit.Allow[1] != "HOME" {
+		t.Errorf("Expected allowlist [PATH HOME], got %+v", inherit)
+	}
+	if cfg.Tools[2].InheritEnv != nil {
+		t.Errorf("Expected no inherit_env setting, got %+v", cfg.Tools[2].InheritEnv)
+	}
+}
+
+func TestLoadConfig_OutputDefaults(t *testing.T) {
+	dir := t.TempDir()
+	configContent := `
+max_output_bytes: 1024
+spill_dir: /tmp/devtool-out
+tools:
+  - name: "inherits"
+    type: "shell"
+    command: "ls"
+  - name: "overrides"
+    type: "shell"
+    command: "ls"
+    max_output_bytes: -1
+`
+	configPath := filepath.Join(dir, "devtool.yaml")
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.Tools[0].MaxOutputBytes != 1024 || cfg.Tools[0].SpillDir != "/tmp/devtool-out" {
+		t.Errorf("Expected tool to inherit output limits, got %d %q", cfg.Tools[0].MaxOutputBytes, cfg.Tools[0].SpillDir)
+	}
+	if cfg.Tools[1].MaxOutputBytes != -1 {
+		t.Errorf("Expected tool override -1, got %d", cfg.Tools[1].MaxOutputBytes)
+	}
+	if expected := filepath.Join(dir, ".devtool/approvals"); cfg.Approvals.Dir != expected {
+		t.Errorf("Expected the approval queue in %s, got %s", expected, cfg.Approvals.Dir)
+	}
+}
+
+func TestLoadConfig_InvalidResources(t *testing.T) {
+	cases := map[string]string{
+		"none":     "resources:\n  - name: empty\n",
+		"two":      "resources:\n  - name: both\n    file: a.txt\n    command: cat a.txt\n",
+		"template": "resources:\n  - name: tmpl\n    glob: '*.log'\n    uri_template: logs://{name}\n",
+	}
+	for name, content := range cases {
+		configPath := filepath.Join(t.TempDir(), "devtool.yaml")
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(configPath); err == nil {
+			t.Errorf("%s: expected the resource to be rejected", name)
+		}
+	}
+}
+
+func TestLoadConfig_ContradictoryAnnotations(t *testing.T) {
+	for _, section := range []string{"tools", "workflows"} {
+		content := section + ":\n  - name: odd\n    annotations:\n      read_only: true\n      destructive: true\n"
+		configPath := filepath.Join(t.TempDir(), "devtool.yaml")
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "read_only and destructive") {
+			t.Errorf("%s: expected read_only with destructive to be rejected, got %v", section, err)
+		}
+	}
+}
+
+func TestHints(t *testing.T) {
+	dir := t.TempDir()
+	configContent := `
+tools:
+  - name: list
+    url: https://api.example.com/items
+  - name: delete
+    url: https://api.example.com/items/{{id}}
+    method: DELETE
+  - name: create
+    url: https://api.example.com/items
+    method: post
+  - name: build
+    type: shell
+    command: make
+    sandbox:
+      writable: [./out]
+  - name: status
+    type: shell
+    command: git status
+    annotations:
+      title: Git status
+      read_only: true
+  - name: purge
+    url: https://api.example.com/cache
+    annotations:
+      destructive: true
+workflows:
+  - name: inspect
+    steps:
+      - tool: list
+      - tool: status
+  - name: release
+    steps:
+      - tool: list
+      - tool: build
+  - name: reviewed
+    annotations:
+      destructive: false
+    steps:
+      - tool: delete
+`
+	configPath := filepath.Join(dir, "devtool.yaml")
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	tools := map[string]Hints{
+		"list":   {ReadOnly: true, Idempotent: true, OpenWorld: true},
+		"delete": {Destructive: true, Idempotent: true, OpenWorld: true},
+		"create": {OpenWorld: true},
+		"build":  {Destructive: true},
+		"status": {Title: "Git status", ReadOnly: true, Idempotent: true, OpenWorld: true},
+		"purge":  {Destructive: true, Idempotent: true, OpenWorld: true},
+	}
+	for _, tool := range cfg.Tools {
+		if got := tool.Hints(); got != tools[tool.Name] {
+			t.Errorf("%s: expected %+v, got %+v", tool.Name, tools[tool.Name], got)
+		}
+	}
+
+	workflows := map[string]Hints{
+		"inspect":  {ReadOnly: true, Idempotent: true, OpenWorld: true},
+		"release":  {Destructive: true, OpenWorld: true},
+		"reviewed": {Idempotent: true, OpenWorld: true},
+	}
+	for _, wf := range cfg.Workflows {
+		if got := wf.Hints(cfg.Tools); got != workflows[wf.Name] {
+			t.Errorf("%s: expected %+v, got %+v", wf.Name, workflows[wf.Name], got)
+		}
+	}
+}
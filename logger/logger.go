@@ -1,8 +1,11 @@
 package logger
 
 import (
+	"context"
+	"devtool/rotate"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"strings"
 	"sync"
@@ -10,53 +13,286 @@ import (
 )
 
 var (
-	logFile io.Writer
 	mu      sync.Mutex
+	logFile *rotate.Writer
+	format  = "text"
+	level   = new(slog.LevelVar)
+	handler slog.Handler
+
+	std = &Logger{}
 )
 
+func init() {
+	rebuild()
+}
+
 // Setup initializes the logger with an output file.
 // It writes to both Stderr and the file.
 func Setup(path string) error {
+	if path == "" {
+		return nil
+	}
+	return SetupFile(rotate.New(path, 0, 0))
+}
+
+// SetupFile is like Setup but writes to a rotating file.
+func SetupFile(w *rotate.Writer) error {
+	// Open eagerly so a bad path is reported at startup
+	if _, err := w.Write(nil); err != nil {
+		return err
+	}
+
 	mu.Lock()
 	defer mu.Unlock()
+	if logFile != nil {
+		logFile.Close()
+	}
+	logFile = w
+	rebuild()
+	return nil
+}
 
-	if path == "" {
+// Reopen reopens the log file, e.g. after logrotate moved it away.
+func Reopen() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if logFile == nil {
 		return nil
 	}
+	return logFile.Reopen()
+}
+
+// Configure sets the minimum level ("debug", "info", "warn" or "error") and
+// the output format ("text" or "json"). Empty values keep the current
+// setting.
+func Configure(lvl, fmtName string) error {
+	if lvl != "" {
+		l, err := ParseLevel(lvl)
+		if err != nil {
+			return err
+		}
+		level.Set(l)
+	}
 
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
+	switch fmtName {
+	case "":
+	case "text", "json":
+		mu.Lock()
+		format = fmtName
+		rebuild()
+		mu.Unlock()
+	default:
+		return fmt.Errorf("unknown log format '%s' (expected text or json)", fmtName)
 	}
-	
-	logFile = f
 	return nil
 }
 
-func write(level, format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	// Trim newline to avoid double spacing if the message already has one, logic below handles it
-	msg = strings.TrimRight(msg, "\n")
-	
-	formatted := fmt.Sprintf("[%s] [%s] %s\n", timestamp, level, msg)
+// ParseLevel converts a level name to a slog.Level.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level '%s'", name)
+}
+
+// rebuild creates the handler for the current format and outputs. Callers
+// must hold mu, except during init.
+func rebuild() {
+	var out io.Writer = os.Stderr
+	if logFile != nil {
+		out = io.MultiWriter(os.Stderr, logFile)
+	}
+	out = &lockedWriter{w: out}
 
+	opts := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		handler = slog.NewJSONHandler(out, opts)
+	} else {
+		handler = &textHandler{w: out, opts: opts}
+	}
+}
+
+func currentHandler() slog.Handler {
 	mu.Lock()
 	defer mu.Unlock()
+	return handler
+}
 
-	// Always write to Stderr
-	os.Stderr.Write([]byte(formatted))
+// Logger writes printf-style messages with a fixed set of contextual
+// fields, such as the session, request ID or tool name.
+type Logger struct {
+	attrs []slog.Attr
+}
 
-	// Write to file if configured
-	if logFile != nil {
-		logFile.Write([]byte(formatted))
+// With returns a logger that adds the given key/value pairs to every record.
+func With(args ...interface{}) *Logger {
+	return std.With(args...)
+}
+
+// With returns a logger with additional key/value pairs.
+func (l *Logger) With(args ...interface{}) *Logger {
+	r := slog.NewRecord(time.Time{}, 0, "", 0)
+	r.Add(args...)
+
+	attrs := append([]slog.Attr{}, l.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return &Logger{attrs: attrs}
+}
+
+func (l *Logger) log(lvl slog.Level, format string, args ...interface{}) {
+	h := currentHandler()
+	subs := subscribers()
+	ctx := context.Background()
+	enabled := h.Enabled(ctx, lvl)
+	if !enabled && len(subs) == 0 {
+		return
+	}
+
+	msg := strings.TrimRight(fmt.Sprintf(format, args...), "\n")
+	r := slog.NewRecord(time.Now(), lvl, msg, 0)
+	r.AddAttrs(l.attrs...)
+	if enabled {
+		h.Handle(ctx, r)
+	}
+	for _, fn := range subs {
+		fn(r.Clone())
 	}
 }
 
-func Info(format string, args ...interface{}) {
-	write("INFO", format, args...)
+var (
+	subMu  sync.Mutex
+	subs   = map[int]func(slog.Record){}
+	nextID int
+)
+
+// Subscribe calls fn for every record logged from now on, regardless of the
+// configured level, until the returned function is called. fn runs on the
+// logging goroutine and must not log itself.
+func Subscribe(fn func(slog.Record)) (unsubscribe func()) {
+	subMu.Lock()
+	defer subMu.Unlock()
+	nextID++
+	id := nextID
+	subs[id] = fn
+	return func() {
+		subMu.Lock()
+		defer subMu.Unlock()
+		delete(subs, id)
+	}
 }
 
-func Error(format string, args ...interface{}) {
-	write("ERROR", format, args...)
+func subscribers() []func(slog.Record) {
+	subMu.Lock()
+	defer subMu.Unlock()
+	if len(subs) == 0 {
+		return nil
+	}
+	fns := make([]func(slog.Record), 0, len(subs))
+	for _, fn := range subs {
+		fns = append(fns, fn)
+	}
+	return fns
+}
+
+func (l *Logger) Debug(format string, args ...interface{}) { l.log(slog.LevelDebug, format, args...) }
+func (l *Logger) Info(format string, args ...interface{})  { l.log(slog.LevelInfo, format, args...) }
+func (l *Logger) Warn(format string, args ...interface{})  { l.log(slog.LevelWarn, format, args...) }
+func (l *Logger) Error(format string, args ...interface{}) { l.log(slog.LevelError, format, args...) }
+
+func Debug(format string, args ...interface{}) { std.log(slog.LevelDebug, format, args...) }
+func Info(format string, args ...interface{})  { std.log(slog.LevelInfo, format, args...) }
+func Warn(format string, args ...interface{})  { std.log(slog.LevelWarn, format, args...) }
+func Error(format string, args ...interface{}) { std.log(slog.LevelError, format, args...) }
+
+// lockedWriter serializes writes so records from concurrent handlers don't
+// interleave.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// textHandler keeps the original "[time] [LEVEL] message" layout and
+// appends attributes as key=value pairs.
+type textHandler struct {
+	w     io.Writer
+	opts  *slog.HandlerOptions
+	attrs []slog.Attr
+	group string
+}
+
+func (h *textHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return lvl >= h.opts.Level.Level()
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] [%s] %s", r.Time.Format("2006-01-02 15:04:05"), r.Level, r.Message)
+
+	for _, a := range h.attrs {
+		writeAttr(&b, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&b, h.group, a)
+		return true
+	})
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, a := range attrs {
+		if h.group != "" {
+			a.Key = h.group + a.Key
+		}
+		h2.attrs = append(h2.attrs, a)
+	}
+	return &h2
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group = h.group + name + "."
+	return &h2
+}
+
+func writeAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			writeAttr(b, prefix+a.Key+".", ga)
+		}
+		return
+	}
+
+	val := a.Value.String()
+	if strings.ContainsAny(val, " \t\n\"=") || val == "" {
+		val = fmt.Sprintf("%q", val)
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, a.Key, val)
 }
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
@@ -42,3 +43,51 @@ func TestLogger(t *testing.T) {
 		t.Errorf("Expected error log not found. Got:\n%s", output)
 	}
 }
+
+func TestLogger_JSONAndLevels(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	if err := Setup(logPath); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if err := Configure("warn", "json"); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	defer Configure("info", "text")
+
+	Info("hidden")
+	With("session", "abc", "tool", "deploy").Warn("slow call: %dms", 1200)
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected only the warning to be logged, got:\n%s", content)
+	}
+
+	var rec map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("Expected a JSON record, got %q: %v", lines[0], err)
+	}
+	if rec["level"] != "WARN" || rec["msg"] != "slow call: 1200ms" || rec["session"] != "abc" || rec["tool"] != "deploy" {
+		t.Errorf("Unexpected record: %v", rec)
+	}
+}
+
+func TestLogger_TextAttrs(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	if err := Setup(logPath); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	With("request_id", 7).Info("Executing %s", "greet")
+
+	content, _ := os.ReadFile(logPath)
+	if !strings.Contains(string(content), "[INFO] Executing greet request_id=7") {
+		t.Errorf("Expected contextual fields in text output, got:\n%s", content)
+	}
+	if err := Configure("verbose", ""); err == nil {
+		t.Errorf("Expected an error for an unknown level")
+	}
+}
@@ -2,17 +2,32 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"devtool/approval"
+	"devtool/audit"
 	"devtool/config"
 	"devtool/logger"
 	"devtool/mcp"
+	"devtool/metrics"
+	"devtool/rotate"
 	"devtool/tools"
+	"devtool/tracing"
 	"encoding/json"
 	"flag"
 	"fmt"
 
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"os/user"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 )
 
 func main() {
@@ -23,6 +38,13 @@ func main() {
 
 	command := os.Args[1]
 
+	// Re-executed inside a tool sandbox to apply resource limits
+	if command == tools.SandboxHelperCommand {
+		err := tools.RunSandboxHelper(os.Args[2:])
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(126)
+	}
+
 	// Common flags
 	var configPath string
 
@@ -35,11 +57,30 @@ func main() {
 	wizardCmd := flag.NewFlagSet("wizard", flag.ExitOnError)
 	wizardCmd.StringVar(&configPath, "config", "devtool.yaml", "Path to configuration file")
 	wizardLog := wizardCmd.String("logfile", "", "Path to log file")
+	wizardYes := wizardCmd.Bool("yes", false, "Run tools that require confirmation without asking")
+
+	approvalsCmd := flag.NewFlagSet("approvals", flag.ExitOnError)
+	approvalsCmd.StringVar(&configPath, "config", "devtool.yaml", "Path to configuration file")
+	approvalsAll := approvalsCmd.Bool("all", false, "List decided requests too")
+
+	auditCmd := flag.NewFlagSet("audit", flag.ExitOnError)
+	auditCmd.StringVar(&configPath, "config", "devtool.yaml", "Path to configuration file")
+	auditTool := auditCmd.String("tool", "", "Only show calls to this tool or workflow")
+	auditIdentity := auditCmd.String("identity", "", "Only show calls by this identity")
+	auditStatus := auditCmd.String("status", "", "Only show calls with this status (ok, error, denied)")
+	auditSince := auditCmd.Duration("since", 0, "Only show calls within this duration (e.g. 24h)")
+	auditLimit := auditCmd.Int("limit", 50, "Show at most this many of the most recent calls (0 for all)")
+	auditJSON := auditCmd.Bool("json", false, "Print raw JSON lines")
 
 	testCmd := flag.NewFlagSet("test", flag.ExitOnError)
 	testAddr := testCmd.String("addr", "", "Address of running MCP server (e.g. localhost:3000)")
 	testLog := testCmd.String("logfile", "", "Path to log file")
 	testWorkflow := testCmd.String("workflow", "", "Name of the workflow/tool to test")
+	testToken := testCmd.String("token", os.Getenv("DEVTOOL_TOKEN"), "Bearer token for servers with authentication (default $DEVTOOL_TOKEN)")
+	testTLS := testCmd.Bool("tls", false, "Connect using TLS")
+	testCA := testCmd.String("ca", "", "CA certificate to verify the server (implies --tls)")
+	testCert := testCmd.String("cert", "", "Client certificate for mutual TLS (implies --tls)")
+	testKey := testCmd.String("key", "", "Client key for mutual TLS")
 
 	switch command {
 	case "serve":
@@ -51,6 +92,12 @@ func main() {
 		}
 
 		setupLogging(*serveLog, cfg)
+		setupAudit(cfg)
+
+		reopenOnSIGHUP()
+		if cfg.Metrics.Addr != "" {
+			go serveMetrics(cfg.Metrics)
+		}
 
 		server := mcp.NewServer(cfg, configPath)
 
@@ -59,10 +106,26 @@ func main() {
 			port = cfg.Server.Port
 		}
 
+		stopTracing := setupTracing(cfg, port == 0)
+
+		// Serve until interrupted, then close upstream connections and
+		// flush outstanding spans before exiting
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 		if port > 0 {
-			server.ServeTCP(port)
+			err = server.ServeTCP(ctx, port)
 		} else {
-			server.ServeStdio()
+			err = server.ServeStdio(ctx)
+		}
+		stop()
+		if err != nil {
+			logger.Error("%v", err)
+		}
+		logger.Info("Shutting down")
+		server.Close()
+		tools.CloseUpstreams()
+		stopTracing()
+		if err != nil {
+			os.Exit(1)
 		}
 
 	case "wizard":
@@ -76,6 +139,10 @@ func main() {
 		}
 
 		setupLogging(*wizardLog, cfg)
+		setupAudit(cfg)
+		stopTracing := setupTracing(cfg, false)
+		defer stopTracing()
+		importTools(cfg)
 
 		// If no tool specified, run wizard
 		if len(args) < 1 {
@@ -120,20 +187,67 @@ func main() {
 			}
 		}
 
-		var output string
-
-		if selectedTool != nil {
-			output, err = tools.ExecuteTool(*selectedTool, toolArgs)
-		} else {
-			output, err = tools.ExecuteWorkflow(*selectedWorkflow, cfg.Tools, toolArgs)
+		requiresApproval := (selectedTool != nil && selectedTool.RequiresApproval()) ||
+			(selectedWorkflow != nil && selectedWorkflow.RequiresApproval(cfg.Tools))
+		if requiresApproval && !*wizardYes && !confirm(bufio.NewReader(os.Stdin), toolName) {
+			auditDenied("cli", selectedTool, selectedWorkflow, toolArgs)
+			fmt.Println("Cancelled.")
+			os.Exit(1)
 		}
 
+		output, stderr, err := execute(cfg, "cli", selectedTool, selectedWorkflow, toolArgs)
+		// Pass the tool's stderr through unchanged
+		os.Stderr.WriteString(stderr)
+
 		if err != nil {
 			logger.Error("Error executing %s: %v\nOutput: %v", toolName, err, output)
+			stopTracing()
 			os.Exit(1)
 		}
 		fmt.Println(output)
 
+	case "approvals":
+		approvalsCmd.Parse(os.Args[2:])
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := runApprovals(cfg, approvalsCmd.Args(), *approvalsAll); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "audit":
+		auditCmd.Parse(os.Args[2:])
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		if cfg.Audit.File == "" {
+			fmt.Fprintln(os.Stderr, "Error: audit.file is not configured")
+			os.Exit(1)
+		}
+
+		filter := audit.Filter{
+			Name:     *auditTool,
+			Identity: *auditIdentity,
+			Status:   *auditStatus,
+			Limit:    *auditLimit,
+		}
+		if *auditSince > 0 {
+			filter.Since = time.Now().Add(-*auditSince)
+		}
+		if err := runAudit(cfg.Audit, filter, *auditJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "version":
+		fmt.Printf("devtool %s (MCP %s)\n", mcp.Version, strings.Join(mcp.SupportedProtocolVersions, ", "))
+
 	case "test":
 		testCmd.Parse(os.Args[2:])
 
@@ -158,7 +272,15 @@ func main() {
 			fmt.Println("Error: --addr required for test (e.g. localhost:3000)")
 			os.Exit(1)
 		}
-		runTest(addr, *testWorkflow)
+		var tlsCfg *tls.Config
+		if *testTLS || *testCA != "" || *testCert != "" {
+			tlsCfg, err = clientTLSConfig(*testCA, *testCert, *testKey)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		runTest(addr, *testWorkflow, *testToken, tlsCfg)
 
 	default:
 		printUsage()
@@ -273,6 +395,10 @@ func runWizard(cfg *config.Config) {
 		if len(params) > 0 {
 			fmt.Println("Please provide the following parameters:")
 			for _, p := range params {
+				choices := suggest(cfg, p, args)
+				for i, c := range choices {
+					fmt.Printf("    %d) %s\n", i+1, c)
+				}
 				fmt.Printf("  %s (%s)", p.Name, p.Description)
 				if p.Required {
 					fmt.Print("*")
@@ -280,7 +406,7 @@ func runWizard(cfg *config.Config) {
 				fmt.Print(": ")
 
 				val, _ := reader.ReadString('\n')
-				val = strings.TrimSpace(val)
+				val = pickChoice(strings.TrimSpace(val), choices)
 
 				if val == "" && p.Required {
 					fmt.Println("Error: This parameter is required.")
@@ -290,12 +416,26 @@ func runWizard(cfg *config.Config) {
 			}
 		}
 
+		requiresApproval := (isTool && selectedTool.RequiresApproval()) ||
+			(!isTool && selectedWorkflow.RequiresApproval(cfg.Tools))
+		if requiresApproval && !confirm(reader, name) {
+			if isTool {
+				auditDenied("wizard", &selectedTool, nil, args)
+			} else {
+				auditDenied("wizard", nil, &selectedWorkflow, args)
+			}
+			fmt.Println("Cancelled.")
+			fmt.Println("\nPress Enter to continue...")
+			reader.ReadString('\n')
+			continue
+		}
+
 		fmt.Println("\nExecuting...")
-		var output string
+		var output, stderr string
 		if isTool {
-			output, err = tools.ExecuteTool(selectedTool, args)
+			output, stderr, err = execute(cfg, "wizard", &selectedTool, nil, args)
 		} else {
-			output, err = tools.ExecuteWorkflow(selectedWorkflow, cfg.Tools, args)
+			output, stderr, err = execute(cfg, "wizard", nil, &selectedWorkflow, args)
 		}
 
 		if err != nil {
@@ -306,17 +446,177 @@ func runWizard(cfg *config.Config) {
 			fmt.Println("Output:")
 			fmt.Println(output)
 		}
+		if stderr != "" {
+			fmt.Println("Stderr:")
+			fmt.Println(stderr)
+		}
 
 		fmt.Println("\nPress Enter to continue...")
 		reader.ReadString('\n')
 	}
 }
 
+// suggest returns the completion values of a wizard parameter. Only
+// read-only tools that don't need approval are run to suggest values.
+func suggest(cfg *config.Config, p config.Parameter, args map[string]interface{}) []string {
+	if p.Complete == nil {
+		return nil
+	}
+	var available []config.ToolConfig
+	for _, t := range cfg.Tools {
+		if !t.RequiresApproval() {
+			available = append(available, t)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	values, _, err := tools.Complete(ctx, p, tools.RunAvailable(available), "", args)
+	if err != nil {
+		logger.Warn("Failed to complete %s: %v", p.Name, err)
+	}
+	if len(values) > 20 {
+		values = values[:20]
+	}
+	return values
+}
+
+// pickChoice resolves a numbered choice or a prefix of exactly one choice;
+// anything else is taken literally.
+func pickChoice(input string, choices []string) string {
+	if n, err := strconv.Atoi(input); err == nil && n >= 1 && n <= len(choices) {
+		return choices[n-1]
+	}
+	if input == "" {
+		return input
+	}
+	var match string
+	for _, c := range choices {
+		if c == input {
+			return c
+		}
+		if strings.HasPrefix(c, input) {
+			if match != "" {
+				return input
+			}
+			match = c
+		}
+	}
+	if match != "" {
+		return match
+	}
+	return input
+}
+
+// confirm asks the user whether to run a tool that requires confirmation.
+func confirm(reader *bufio.Reader, name string) bool {
+	fmt.Printf("%s requires confirmation. Run it? [y/N]: ", name)
+	answer, _ := reader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func runApprovals(cfg *config.Config, args []string, all bool) error {
+	queue := approval.NewQueue(cfg.Approvals.Dir)
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "list":
+		reqs, err := queue.List()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTATUS\tTOOL\tREQUESTER\tCREATED")
+		for _, r := range reqs {
+			if !all && r.Status != approval.StatusPending {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.ID, r.Status, r.Tool, r.Requester, r.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+		}
+		return w.Flush()
+
+	case "show", "approve", "deny":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: devtool approvals %s <id>", action)
+		}
+
+		var req *approval.Request
+		var err error
+		switch action {
+		case "show":
+			req, err = queue.Get(args[1])
+		default:
+			req, err = queue.Decide(args[1], action == "approve", currentUser())
+		}
+		if err != nil {
+			return err
+		}
+
+		// Requests queued by older versions may hold secrets
+		var params []config.Parameter
+		for _, t := range cfg.Tools {
+			if t.Name == req.Tool {
+				params = t.Parameters
+				break
+			}
+		}
+		req.Arguments = audit.Redact(req.Arguments, params, audit.Patterns(cfg.Audit))
+
+		out, _ := json.MarshalIndent(req, "", "  ")
+		fmt.Println(string(out))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown approvals action '%s' (expected list, show, approve or deny)", action)
+	}
+}
+
+func runAudit(cfg config.AuditConfig, filter audit.Filter, asJSON bool) error {
+	records, err := audit.Query(cfg.File, cfg.MaxBackups, filter)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, r := range records {
+			enc.Encode(r)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tIDENTITY\tTRANSPORT\tNAME\tSTATUS\tEXIT\tDURATION\tERROR")
+	for _, r := range records {
+		exit := "-"
+		if r.ExitCode != nil {
+			exit = fmt.Sprintf("%d", *r.ExitCode)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%dms\t%s\n", r.Time.Local().Format("2006-01-02 15:04:05"),
+			r.Identity, r.Transport, r.Name, r.Status, exit, r.DurationMs, r.Error)
+	}
+	return w.Flush()
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
 func printUsage() {
 	fmt.Println("Usage:")
 	fmt.Println("  devtool serve --config <path> [--port <port>] [--logfile <path>]")
-	fmt.Println("  devtool wizard [tool-name] [key=value ...] --config <path> [--logfile <path>]")
-	fmt.Println("  devtool test --addr <host:port> [--logfile <path>] [--workflow <name>]")
+	fmt.Println("  devtool wizard [--yes] [tool-name] [key=value ...] --config <path> [--logfile <path>]")
+	fmt.Println("  devtool approvals [--all] [list | show <id> | approve <id> | deny <id>] --config <path>")
+	fmt.Println("  devtool audit [--tool <name>] [--identity <name>] [--status <status>] [--since <duration>] [--limit <n>] [--json] --config <path>")
+	fmt.Println("  devtool version")
+	fmt.Println("  devtool test --addr <host:port> [--token <token>] [--tls] [--ca <path>] [--cert <path> --key <path>] [--logfile <path>] [--workflow <name>]")
 }
 
 func setupLogging(logPath string, cfg *config.Config) {
@@ -324,15 +624,180 @@ func setupLogging(logPath string, cfg *config.Config) {
 	if path == "" && cfg != nil && cfg.LogFile != "" {
 		path = cfg.LogFile
 	}
-	if err := logger.Setup(path); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to setup logging: %v\n", err)
+	if path != "" {
+		w := rotate.New(path, 0, 0)
+		if cfg != nil {
+			w = rotate.New(path, cfg.Logging.MaxSizeMB, cfg.Logging.MaxBackups)
+			w.Interval = cfg.Logging.RotateEvery
+			w.MaxAge = time.Duration(cfg.Logging.MaxAgeDays) * 24 * time.Hour
+			w.Compress = cfg.Logging.Compress
+		}
+		if err := logger.SetupFile(w); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to setup logging: %v\n", err)
+		}
+	}
+	if cfg != nil {
+		if err := logger.Configure(cfg.Logging.Level, cfg.Logging.Format); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to setup logging: %v\n", err)
+		}
 	}
 }
 
-func runTest(addr string, workflowFilter string) {
+// reopenOnSIGHUP reopens the log and audit files when the process receives
+// SIGHUP, so external tools like logrotate can move them away.
+func reopenOnSIGHUP() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			if err := logger.Reopen(); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to reopen log file: %v\n", err)
+			}
+			if err := audit.Reopen(); err != nil {
+				logger.Error("Failed to reopen audit log: %v", err)
+			}
+			logger.Info("Reopened log files")
+		}
+	}()
+}
+
+func serveMetrics(cfg config.MetricsConfig) {
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Path, metrics.Handler())
+	logger.Info("Serving metrics on http://%s%s", cfg.Addr, cfg.Path)
+	// Timeouts keep slow or idle scrapers from holding connections open
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+	if err := srv.ListenAndServe(); err != nil {
+		logger.Error("Metrics server failed: %v", err)
+	}
+}
+
+// setupTracing starts the configured trace exporter and returns a function
+// that flushes it. In Stdio mode stdout carries the MCP protocol, so the
+// stdout exporter writes to stderr instead.
+func setupTracing(cfg *config.Config, stdio bool) func() {
+	tracingCfg := cfg.Tracing
+	if stdio && tracingCfg.Exporter == "stdout" {
+		tracingCfg.Exporter = "stderr"
+	}
+
+	stop, err := tracing.Setup(tracingCfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to setup tracing: %v\n", err)
+		os.Exit(1)
+	}
+	return stop
+}
+
+// importTools replaces the MCP server entries of cfg with the tools they
+// import, warning about servers that can't be reached.
+func importTools(cfg *config.Config) {
+	imported, err := tools.ImportTools(context.Background(), cfg.Tools)
+	if err != nil {
+		logger.Warn("Failed to import MCP tools: %v", err)
+	}
+	cfg.Tools = imported
+}
+
+func setupAudit(cfg *config.Config) {
+	if err := audit.Setup(cfg.Audit); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open audit log: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// execute runs a tool or workflow from the command line and records it in
+// the audit log.
+func execute(cfg *config.Config, transport string, tool *config.ToolConfig, wf *config.WorkflowConfig, args map[string]interface{}) (string, string, error) {
+	rec, params := auditRecord(transport, tool, wf, args)
+	start := time.Now()
+
+	var output, stderr string
+	var err error
+	if tool != nil {
+		var res *tools.Result
+		res, err = tools.RunTool(context.Background(), *tool, args)
+		output, stderr = res.Output, res.Stderr
+		if tool.Type == "shell" {
+			exitCode := res.ExitCode
+			rec.ExitCode = &exitCode
+		}
+	} else {
+		output, err = tools.ExecuteWorkflow(*wf, cfg.Tools, args)
+	}
+
+	rec.DurationMs = time.Since(start).Milliseconds()
+	rec.Status = audit.StatusOK
+	rec.OutputHash = audit.Hash(output)
+	if err != nil {
+		rec.Status = audit.StatusError
+		rec.Error = err.Error()
+	}
+	audit.Log(rec, params)
+
+	return output, stderr, err
+}
+
+// auditDenied records a run the user declined to confirm.
+func auditDenied(transport string, tool *config.ToolConfig, wf *config.WorkflowConfig, args map[string]interface{}) {
+	rec, params := auditRecord(transport, tool, wf, args)
+	rec.Status = audit.StatusDenied
+	rec.Error = "not confirmed"
+	audit.Log(rec, params)
+}
+
+func auditRecord(transport string, tool *config.ToolConfig, wf *config.WorkflowConfig, args map[string]interface{}) (audit.Record, []config.Parameter) {
+	rec := audit.Record{Identity: currentUser(), Transport: transport, Arguments: args}
+	if tool != nil {
+		rec.Kind, rec.Name = "tool", tool.Name
+		return rec, tool.Parameters
+	}
+	rec.Kind, rec.Name = "workflow", wf.Name
+	return rec, wf.Parameters
+}
+
+func clientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+func runTest(addr string, workflowFilter string, token string, tlsCfg *tls.Config) {
 	fmt.Printf("Connecting to MCP server at %s...\n", addr)
 
-	conn, err := net.Dial("tcp", addr)
+	var conn net.Conn
+	var err error
+	if tlsCfg != nil {
+		conn, err = tls.Dial("tcp", addr, tlsCfg)
+	} else {
+		conn, err = net.Dial("tcp", addr)
+	}
 	if err != nil {
 		logger.Error("Failed to connect to server: %v", err)
 		os.Exit(1)
@@ -341,6 +806,8 @@ func runTest(addr string, workflowFilter string) {
 
 	// Use connection for both reading and writing
 	scanner := bufio.NewScanner(conn)
+	// Tool results can be much larger than the default 64 KiB line limit
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
 	// Output writer
 	writer := conn
 
@@ -364,40 +831,78 @@ func runTest(addr string, workflowFilter string) {
 		}
 		writer.Write([]byte("\n"))
 
-		if scanner.Scan() {
+		for scanner.Scan() {
 			var resp mcp.JSONRPCResponse
 			if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
 				logger.Error("Parse response failed: %v", err)
 			}
+			// Skip notifications such as forwarded server logs
+			if resp.ID == nil {
+				continue
+			}
 			return resp
 		}
 		return mcp.JSONRPCResponse{}
 	}
 
+	// 0. Authenticate
+	if token != "" {
+		fmt.Println("\n--- Authenticating ---")
+		resp := send("authenticate", mcp.AuthParams{Token: token})
+		if resp.Error != nil {
+			fmt.Printf("Authentication failed: %v\n", resp.Error.Message)
+			return
+		}
+		fmt.Printf("Authenticated: %v\n", resp.Result)
+	}
+
 	// 1. Initialize
 	fmt.Println("\n--- Sending initialize ---")
-	resp := send("initialize", nil)
+	resp := send("initialize", mcp.InitializeParams{
+		ProtocolVersion: mcp.LatestProtocolVersion,
+		Capabilities:    map[string]interface{}{},
+		ClientInfo:      mcp.Implementation{Name: "devtool-test", Version: mcp.Version},
+	})
 	if resp.Error != nil {
 		fmt.Printf("Initialize failed: %v\n", resp.Error)
 		return
 	}
-	fmt.Println("Initialize success.")
+	var initResult mcp.InitializeResult
+	rb, _ := json.Marshal(resp.Result)
+	json.Unmarshal(rb, &initResult)
+	fmt.Printf("Initialize success: %s %s (protocol %s)\n", initResult.ServerInfo.Name, initResult.ServerInfo.Version, initResult.ProtocolVersion)
 
-	// 2. List tools
-	fmt.Println("\n--- Listing tools ---")
-	resp = send("tools/list", nil)
-	if resp.Error != nil {
-		fmt.Printf("List tools failed: %v\n", resp.Error)
-		return
-	}
+	// Notifications get no response
+	notification, _ := json.Marshal(map[string]string{"jsonrpc": "2.0", "method": "notifications/initialized"})
+	writer.Write(append(notification, '\n'))
 
+	// 2. List tools, following pages
+	fmt.Println("\n--- Listing tools ---")
 	var listsResult struct {
 		Tools []mcp.Tool `json:"tools"`
 	}
-	// The result is actually an interface{}, we need to marshal/unmarshal or mapstructure it
-	// But since we are in the same codebase, we know the structure but resp.Result is generic.
-	rb, _ := json.Marshal(resp.Result)
-	json.Unmarshal(rb, &listsResult)
+	var params mcp.ListToolsParams
+	for {
+		resp = send("tools/list", params)
+		if resp.Error != nil {
+			fmt.Printf("List tools failed: %v\n", resp.Error)
+			return
+		}
+
+		var page struct {
+			Tools      []mcp.Tool `json:"tools"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		// The result is actually an interface{}, we need to marshal/unmarshal or mapstructure it
+		// But since we are in the same codebase, we know the structure but resp.Result is generic.
+		rb, _ = json.Marshal(resp.Result)
+		json.Unmarshal(rb, &page)
+		listsResult.Tools = append(listsResult.Tools, page.Tools...)
+		if page.NextCursor == "" {
+			break
+		}
+		params.Cursor = page.NextCursor
+	}
 
 	fmt.Printf("Found %d tools.\n", len(listsResult.Tools))
 
@@ -414,8 +919,9 @@ func runTest(addr string, workflowFilter string) {
 		// Provide dummy args for required params
 		if tool.InputSchema.Required != nil {
 			for _, req := range tool.InputSchema.Required {
-				prop := tool.InputSchema.Properties[req].(map[string]interface{})
-				pType := prop["type"].(string)
+				// Imported tools may have properties without a plain type
+				prop, _ := tool.InputSchema.Properties[req].(map[string]interface{})
+				pType, _ := prop["type"].(string)
 
 				if pType == "string" {
 					args[req] = "test-value"
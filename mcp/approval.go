@@ -0,0 +1,98 @@
+package mcp
+
+import (
+	"context"
+	"devtool/approval"
+	"devtool/audit"
+	"devtool/config"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// approve asks a human to confirm a tool execution: through the client via
+// elicitation when it supports it, otherwise through the approval queue
+// served by `devtool approvals`. It returns the reason when not approved.
+// Waiting ends when ctx does, e.g. because the client disconnected.
+// Arguments are stored in the queue redacted like audit records.
+func (s *Server) approve(ctx context.Context, sess *session, name string, args map[string]interface{}, params []config.Parameter) (bool, string) {
+	s.mu.RLock()
+	cfg := s.Config.Approvals
+	s.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	if sess.clientSupports("elicitation") {
+		approved, reason, err := elicitApproval(ctx, sess, name, args)
+		if err == nil {
+			return approved, reason
+		}
+		sess.log.Error("Elicitation for %s failed, falling back to approval queue: %v", name, err)
+	}
+
+	queue := approval.NewQueue(cfg.Dir)
+	req, err := queue.Submit(name, audit.Redacted(args, params), sess.identity.Name)
+	if err != nil {
+		return false, fmt.Sprintf("failed to queue approval request: %v", err)
+	}
+	sess.log.Info("Approval required for %s. Run 'devtool approvals approve %s' to allow it.", name, req.ID)
+
+	req, err = queue.Wait(ctx, req.ID, time.Second)
+	if err != nil {
+		return false, fmt.Sprintf("failed to wait for approval: %v", err)
+	}
+
+	switch req.Status {
+	case approval.StatusApproved:
+		return true, ""
+	case approval.StatusDenied:
+		return false, fmt.Sprintf("denied by %s", req.DecidedBy)
+	default:
+		return false, fmt.Sprintf("no decision within %s (approval %s)", cfg.Timeout, req.ID)
+	}
+}
+
+// elicitApproval asks the client's user through an elicitation/create request.
+func elicitApproval(ctx context.Context, sess *session, name string, args map[string]interface{}) (bool, string, error) {
+	argsJSON, _ := json.Marshal(args)
+	params := map[string]interface{}{
+		"message": fmt.Sprintf("The agent wants to run '%s' with arguments %s. Allow it?", name, argsJSON),
+		"requestedSchema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"approve": map[string]interface{}{
+					"type":        "boolean",
+					"title":       "Approve",
+					"description": fmt.Sprintf("Run %s", name),
+				},
+			},
+			"required": []string{"approve"},
+		},
+	}
+
+	raw, err := sess.request(ctx, "elicitation/create", params)
+	if err != nil {
+		return false, "", err
+	}
+
+	var result struct {
+		Action  string                 `json:"action"`
+		Content map[string]interface{} `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return false, "", fmt.Errorf("invalid elicitation result: %w", err)
+	}
+
+	switch result.Action {
+	case "accept":
+		if approved, _ := result.Content["approve"].(bool); approved {
+			return true, "", nil
+		}
+		return false, "rejected by the user", nil
+	case "decline":
+		return false, "declined by the user", nil
+	default:
+		return false, "cancelled by the user", nil
+	}
+}
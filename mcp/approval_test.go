@@ -0,0 +1,58 @@
+package mcp
+
+import (
+	"devtool/approval"
+	"devtool/config"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestApprovalCancelledOnDisconnect(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "ran")
+	queueDir := filepath.Join(dir, "approvals")
+	s := NewServer(&config.Config{
+		Tools:     []config.ToolConfig{{Name: "deploy", Type: "shell", Command: "touch " + marker, Confirm: true}},
+		Approvals: config.ApprovalConfig{Dir: queueDir, Timeout: time.Minute},
+	}, "")
+
+	inR, inW := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		s.serveStream(inR, io.Discard, localIdentity)
+		close(done)
+	}()
+	io.WriteString(inW, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`+"\n")
+	io.WriteString(inW, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"deploy","arguments":{"api_token":"s3cr3t"}}}`+"\n")
+
+	queue := approval.NewQueue(queueDir)
+	var reqs []*approval.Request
+	for deadline := time.Now().Add(5 * time.Second); len(reqs) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the approval request")
+		}
+		time.Sleep(10 * time.Millisecond)
+		reqs, _ = queue.List()
+	}
+	if reqs[0].Arguments["api_token"] != "[REDACTED]" {
+		t.Errorf("Expected the queued arguments to be redacted, got %v", reqs[0].Arguments)
+	}
+
+	// The client goes away while the call waits for approval
+	inW.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Session did not end while a call was waiting for approval")
+	}
+
+	if _, err := queue.Decide(reqs[0].ID, true, "alice"); err == nil {
+		t.Error("Expected the request to be expired after the disconnect")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected the tool not to run after the client disconnected")
+	}
+}
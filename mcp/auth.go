@@ -0,0 +1,191 @@
+package mcp
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"devtool/config"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Error code returned when a TCP client fails to authenticate
+const codeUnauthorized = -32001
+
+// authTimeout bounds how long a new connection may take to authenticate.
+const authTimeout = 10 * time.Second
+
+// Identity describes who is on the other end of a connection.
+type Identity struct {
+	Name      string // Token name, client certificate CN, or "local"/"anonymous"
+	Method    string // "token", "tls" or "none"
+	Transport string // "stdio" or "tcp"
+	Remote    string // Remote address for TCP
+}
+
+// AuthParams are the params of the "authenticate" request a TCP client must
+// send first when tokens are configured.
+type AuthParams struct {
+	Token string `json:"token"`
+}
+
+// listen opens the TCP listener, wrapping it in TLS when configured.
+func listen(cfg config.ServerConfig, port int) (net.Listener, error) {
+	addr := net.JoinHostPort(cfg.Bind, fmt.Sprintf("%d", port))
+	if cfg.TLS == nil {
+		return net.Listen("tcp", addr)
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.TLS.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLS.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.TLS.ClientCAFile)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tls.Listen("tcp", addr, tlsCfg)
+}
+
+// authenticate establishes the identity of a new TCP connection. With mutual
+// TLS the client certificate identifies the caller; when tokens are
+// configured the first message must be an "authenticate" request carrying a
+// valid token. The returned reader must be used for the rest of the stream.
+func (s *Server) authenticate(conn net.Conn) (*bufio.Reader, Identity, error) {
+	reader := bufio.NewReader(conn)
+	id := Identity{Name: "anonymous", Method: "none", Transport: "tcp", Remote: conn.RemoteAddr().String()}
+
+	s.mu.RLock()
+	auth := s.Config.Server.Auth
+	s.mu.RUnlock()
+
+	conn.SetDeadline(time.Now().Add(authTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, id, fmt.Errorf("TLS handshake failed: %w", err)
+		}
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			id.Name = certs[0].Subject.CommonName
+			id.Method = "tls"
+		}
+	}
+
+	if !auth.Enabled() {
+		return reader, id, nil
+	}
+
+	line, err := reader.ReadSlice('\n')
+	if err != nil {
+		return nil, id, fmt.Errorf("failed to read authenticate request: %w", err)
+	}
+
+	var req JSONRPCRequest
+	var params AuthParams
+	if err := json.Unmarshal(line, &req); err != nil || req.Method != "authenticate" {
+		writeAuthError(conn, nil, "Unauthorized: first message must be an authenticate request")
+		return nil, id, fmt.Errorf("expected authenticate request")
+	}
+	json.Unmarshal(req.Params, &params)
+
+	tokens, err := loadTokens(auth)
+	if err != nil {
+		writeAuthError(conn, req.ID, "Unauthorized")
+		return nil, id, err
+	}
+
+	name, ok := matchToken(tokens, params.Token)
+	if !ok {
+		writeAuthError(conn, req.ID, "Unauthorized: invalid token")
+		return nil, id, fmt.Errorf("invalid token")
+	}
+	id.Name = name
+	id.Method = "token"
+
+	resp := JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  map[string]string{"identity": name},
+	}
+	bytes, _ := json.Marshal(resp)
+	if _, err := conn.Write(append(bytes, '\n')); err != nil {
+		return nil, id, err
+	}
+
+	return reader, id, nil
+}
+
+func writeAuthError(conn net.Conn, id interface{}, msg string) {
+	resp := JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   &JSONRPCError{Code: codeUnauthorized, Message: msg},
+	}
+	bytes, _ := json.Marshal(resp)
+	conn.Write(append(bytes, '\n'))
+}
+
+// loadTokens returns the configured tokens keyed by name.
+func loadTokens(auth config.AuthConfig) (map[string]string, error) {
+	tokens := make(map[string]string)
+	for _, t := range auth.Tokens {
+		if token := os.ExpandEnv(t.Token); token != "" {
+			tokens[t.Name] = token
+		}
+	}
+
+	if auth.TokensFile != "" {
+		data, err := os.ReadFile(auth.TokensFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tokens file: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			name, token, ok := strings.Cut(line, ":")
+			if !ok || strings.TrimSpace(token) == "" {
+				continue
+			}
+			tokens[strings.TrimSpace(name)] = strings.TrimSpace(token)
+		}
+	}
+
+	return tokens, nil
+}
+
+// matchToken finds the name of token, comparing in constant time.
+func matchToken(tokens map[string]string, token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+
+	var match string
+	for name, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			match = name
+		}
+	}
+	return match, match != ""
+}
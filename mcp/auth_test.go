@@ -0,0 +1,66 @@
+package mcp
+
+import (
+	"devtool/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newAuthServer(t *testing.T) *Server {
+	t.Helper()
+	t.Setenv("DEVTOOL_TEST_TOKEN", "env-secret")
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(tokensFile, []byte("# agents\nci-bot: file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewServer(&config.Config{
+		Server: config.ServerConfig{
+			Auth: config.AuthConfig{
+				Tokens:     []config.TokenConfig{{Name: "alice", Token: "${DEVTOOL_TEST_TOKEN}"}},
+				TokensFile: tokensFile,
+			},
+		},
+	}, "")
+}
+
+func TestAuthenticate_Token(t *testing.T) {
+	s := newAuthServer(t)
+
+	cases := map[string]string{
+		"env-secret":  "alice",
+		"file-secret": "ci-bot",
+	}
+	for token, name := range cases {
+		resp, id, err := authenticatePipe(t, s, `{"jsonrpc":"2.0","id":1,"method":"authenticate","params":{"token":"`+token+`"}}`)
+		if err != nil {
+			t.Fatalf("authenticate failed for %s: %v", name, err)
+		}
+		if id.Name != name || id.Method != "token" {
+			t.Errorf("Expected identity %s via token, got %+v", name, id)
+		}
+		if resp.Error != nil {
+			t.Errorf("Expected success response, got %+v", resp.Error)
+		}
+	}
+}
+
+func TestAuthenticate_Rejected(t *testing.T) {
+	s := newAuthServer(t)
+
+	for _, line := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"authenticate","params":{"token":"wrong"}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"authenticate","params":{}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`,
+	} {
+		resp, _, err := authenticatePipe(t, s, line)
+		if err == nil {
+			t.Errorf("Expected %s to be rejected", line)
+		}
+		if resp.Error == nil || resp.Error.Code != codeUnauthorized {
+			t.Errorf("Expected unauthorized error for %s, got %+v", line, resp)
+		}
+	}
+}
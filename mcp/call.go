@@ -0,0 +1,146 @@
+package mcp
+
+import (
+	"context"
+	"devtool/audit"
+	"devtool/config"
+	"devtool/logger"
+	"devtool/tools"
+	"devtool/tracing"
+	"errors"
+	"time"
+)
+
+// errUnknownTool is returned by authorizeAndRun for tools and workflows that
+// don't exist or are hidden by the session's toolsets.
+var errUnknownTool = errors.New("unknown tool")
+
+// errAccessDenied is returned by authorizeAndRun for names the policies
+// don't grant the caller, whether or not such a tool exists, so that
+// callers can't probe which tools exist.
+var errAccessDenied = errors.New("access denied by policy")
+
+// errRequiresApproval is returned by authorizeAndRun for tools that require
+// approval when no human can be asked.
+var errRequiresApproval = errors.New("it requires approval")
+
+// errClientGone is returned by authorizeAndRun when the client disconnected
+// before the tool ran.
+var errClientGone = errors.New("client disconnected")
+
+// notApprovedError is returned by authorizeAndRun when a human didn't
+// approve a call.
+type notApprovedError struct {
+	reason string
+}
+
+func (e *notApprovedError) Error() string { return "not approved: " + e.reason }
+
+// authorizeAndRun runs the tool or workflow called name on behalf of sess.
+// The caller must be granted the tool, or the workflow and every step's
+// tool, by the policies, and it must be in the session's toolsets. Tools
+// that require approval are submitted for it when interactive is set and
+// refused otherwise. Every refusal and execution is recorded with
+// recordCall.
+//
+// It returns the tool that ran, which is nil for workflows, and its result.
+// Errors other than the ones declared above come from the execution and are
+// returned along with its result.
+func (s *Server) authorizeAndRun(ctx context.Context, span *tracing.Span, log *logger.Logger, sess *session, cfg *config.Config, name string, args map[string]interface{}, interactive bool) (*config.ToolConfig, *tools.Result, error) {
+	var tool *config.ToolConfig
+	var wf *config.WorkflowConfig
+	for i := range cfg.Tools {
+		if cfg.Tools[i].Name == name {
+			tool = &cfg.Tools[i]
+			break
+		}
+	}
+	if tool == nil {
+		for i := range cfg.Workflows {
+			if cfg.Workflows[i].Name == name {
+				wf = &cfg.Workflows[i]
+				break
+			}
+		}
+	}
+	if tool == nil && wf == nil {
+		if !isAllowed(cfg.Policies, sess.identity, name, nil) {
+			log.Error("Denied call to %s by %s (%s)", name, sess.identity.Name, sess.identity.Transport)
+			return nil, nil, errAccessDenied
+		}
+		return nil, nil, errUnknownTool
+	}
+
+	rec := audit.Record{Identity: sess.identity.Name, Transport: sess.identity.Transport, Session: sess.id, Name: name, Arguments: args}
+	var tags []string
+	var params []config.Parameter
+	if tool != nil {
+		rec.Kind, tags, params = "tool", tool.Tags, tool.Parameters
+	} else {
+		rec.Kind, tags, params = "workflow", wf.Tags, wf.Parameters
+	}
+	start := time.Now()
+
+	allowed := isAllowed(cfg.Policies, sess.identity, name, tags)
+	if wf != nil {
+		allowed = workflowAllowed(cfg.Policies, sess.identity, *wf, cfg.Tools)
+	}
+	if !allowed {
+		log.Error("Denied call to %s by %s (%s)", name, sess.identity.Name, sess.identity.Transport)
+		rec.Status, rec.Error = audit.StatusDenied, errAccessDenied.Error()
+		recordCall(span, rec, params, start)
+		return nil, nil, errAccessDenied
+	}
+	// Tools outside the session's toolsets are refused like unknown tools.
+	// This is no access control, as the session can select other toolsets.
+	if !inToolsets(s.toolsets(sess), tags) {
+		rec.Status, rec.Error = audit.StatusDenied, "not in the session's toolsets"
+		recordCall(span, rec, params, start)
+		return nil, nil, errUnknownTool
+	}
+
+	if (tool != nil && tool.RequiresApproval()) || (wf != nil && wf.RequiresApproval(cfg.Tools)) {
+		if !interactive {
+			rec.Status, rec.Error = audit.StatusDenied, "requires approval"
+			recordCall(span, rec, params, start)
+			return nil, nil, errRequiresApproval
+		}
+		if approved, reason := s.approve(ctx, sess, name, args, params); !approved {
+			log.Info("Execution of %s not approved: %s", name, reason)
+			rec.Status, rec.Error = audit.StatusDenied, "not approved: "+reason
+			recordCall(span, rec, params, start)
+			return nil, nil, &notApprovedError{reason: reason}
+		}
+	}
+
+	// Nothing runs for a client that is gone, even if approved
+	if ctx.Err() != nil {
+		log.Info("Not executing %s: the client disconnected", name)
+		rec.Status, rec.Error = audit.StatusError, "client disconnected"
+		recordCall(span, rec, params, start)
+		return nil, nil, errClientGone
+	}
+
+	log.Info("Executing %s with params: %v", name, audit.Redacted(args, params))
+
+	var res *tools.Result
+	var err error
+	if tool != nil {
+		res, err = tools.RunTool(ctx, *tool, args)
+		if tool.Type == "shell" {
+			exitCode := res.ExitCode
+			rec.ExitCode = &exitCode
+		}
+	} else {
+		res = &tools.Result{}
+		res.Output, err = tools.RunWorkflow(ctx, *wf, cfg.Tools, args)
+	}
+
+	rec.Status = audit.StatusOK
+	rec.OutputHash = audit.Hash(res.Output)
+	if err != nil {
+		rec.Status, rec.Error = audit.StatusError, err.Error()
+	}
+	recordCall(span, rec, params, start)
+	return tool, res, err
+}
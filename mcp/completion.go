@@ -0,0 +1,113 @@
+package mcp
+
+import (
+	"context"
+	"devtool/config"
+	"devtool/tools"
+	"fmt"
+	"time"
+)
+
+// completionTimeout bounds how long completion commands and tools may run.
+const completionTimeout = 10 * time.Second
+
+type CompleteParams struct {
+	Ref struct {
+		Type string `json:"type"` // "ref/prompt", "ref/resource" or "ref/tool"
+		Name string `json:"name"`
+		URI  string `json:"uri"`
+	} `json:"ref"`
+	Argument struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"argument"`
+	Context struct {
+		Arguments map[string]string `json:"arguments"`
+	} `json:"context"`
+}
+
+// errInvalidRef is returned by complete for unsupported references.
+var errInvalidRef = fmt.Errorf("invalid completion reference")
+
+type Completion struct {
+	Values  []string `json:"values"`
+	Total   int      `json:"total"`
+	HasMore bool     `json:"hasMore"`
+}
+
+// complete suggests values for a prompt, resource template, or tool
+// argument. Besides the standard ref/prompt and ref/resource, ref/tool
+// completes the parameters of tools and workflows the caller may use.
+// Completion tools must be read-only. They are authorized and recorded in
+// the audit log like a tools/call, except that tools requiring approval are
+// refused.
+func (s *Server) complete(ctx context.Context, sess *session, params CompleteParams) (Completion, error) {
+	ctx, cancel := context.WithTimeout(ctx, completionTimeout)
+	defer cancel()
+	cfg := s.snapshot()
+
+	var candidates []config.Parameter
+	switch params.Ref.Type {
+	case "ref/prompt":
+		for _, p := range cfg.Prompts {
+			if p.Name == params.Ref.Name {
+				candidates = p.Arguments
+			}
+		}
+	case "ref/resource":
+		// Resource template variables have no completion sources
+	case "ref/tool":
+		for _, t := range cfg.Tools {
+			if t.Name == params.Ref.Name && isAllowed(cfg.Policies, sess.identity, t.Name, t.Tags) {
+				candidates = t.Parameters
+			}
+		}
+		for _, w := range cfg.Workflows {
+			if candidates == nil && w.Name == params.Ref.Name && workflowAllowed(cfg.Policies, sess.identity, w, cfg.Tools) {
+				candidates = w.Parameters
+			}
+		}
+	default:
+		return Completion{}, fmt.Errorf("%w: unknown ref type '%s'", errInvalidRef, params.Ref.Type)
+	}
+
+	completion := Completion{Values: []string{}}
+	var param *config.Parameter
+	for i := range candidates {
+		if candidates[i].Name == params.Argument.Name {
+			param = &candidates[i]
+		}
+	}
+	if param == nil || param.Complete == nil {
+		return completion, nil
+	}
+
+	run := func(ctx context.Context, name string, args map[string]interface{}) (*tools.Result, error) {
+		for _, t := range cfg.Tools {
+			if t.Name == name && t.Hints().ReadOnly {
+				_, res, err := s.authorizeAndRun(ctx, nil, sess.log.With("tool", name), sess, cfg, name, args, false)
+				return res, err
+			}
+		}
+		return nil, fmt.Errorf("not available for completion")
+	}
+
+	// Only arguments of declared parameters are passed on
+	args := make(map[string]interface{}, len(params.Context.Arguments))
+	for _, c := range candidates {
+		if v, ok := params.Context.Arguments[c.Name]; ok {
+			args[c.Name] = v
+		}
+	}
+
+	values, total, err := tools.Complete(ctx, *param, run, params.Argument.Value, args)
+	if err != nil {
+		return completion, err
+	}
+	if values != nil {
+		completion.Values = values
+	}
+	completion.Total = total
+	completion.HasMore = total > len(values)
+	return completion, nil
+}
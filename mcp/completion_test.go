@@ -0,0 +1,91 @@
+package mcp
+
+import (
+	"devtool/audit"
+	"devtool/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompletion(t *testing.T) {
+	auditFile := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := audit.Setup(config.AuditConfig{File: auditFile}); err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Setup(config.AuditConfig{})
+
+	readOnly := true
+	envs := &config.CompleteConfig{Values: []string{"prod", "preview", "staging"}}
+	s := NewServer(&config.Config{
+		Tools: []config.ToolConfig{
+			{Name: "deploy", Type: "shell", Command: "true", Parameters: []config.Parameter{{Name: "env", Complete: envs}}},
+			{Name: "secret-list", Type: "shell", Command: "echo hidden", Tags: []string{"admin"}, Annotations: &config.Annotations{ReadOnly: &readOnly}},
+			{Name: "leak", Type: "shell", Command: "true", Parameters: []config.Parameter{
+				{Name: "x", Complete: &config.CompleteConfig{Tool: "secret-list"}},
+			}},
+			{Name: "list-branches", Type: "shell", Command: "printf 'main\\nrelease\\n'", Annotations: &config.Annotations{ReadOnly: &readOnly}},
+			{Name: "create-branch", Type: "shell", Command: "echo created"},
+			{Name: "checkout", Type: "shell", Command: "true", Parameters: []config.Parameter{
+				{Name: "branch", Complete: &config.CompleteConfig{Tool: "list-branches"}},
+				{Name: "new", Complete: &config.CompleteConfig{Tool: "create-branch"}},
+			}},
+		},
+		Prompts: []config.PromptConfig{{Name: "release", Arguments: []config.Parameter{{Name: "env", Complete: envs}}}},
+		Policies: []config.PolicyConfig{
+			{Identities: []string{"local"}, Allow: []string{"deploy", "leak", "checkout", "list-branches", "create-branch"}},
+		},
+	}, "")
+	sess, msgs := testSession(t)
+
+	complete := func(params string) map[string]interface{} {
+		resp := call(t, s, sess, msgs, "completion/complete", params)
+		if resp["error"] != nil {
+			return resp
+		}
+		return resp["result"].(map[string]interface{})["completion"].(map[string]interface{})
+	}
+	values := func(c map[string]interface{}) []string {
+		out := []string{}
+		for _, v := range c["values"].([]interface{}) {
+			out = append(out, v.(string))
+		}
+		return out
+	}
+
+	c := complete(`{"ref":{"type":"ref/prompt","name":"release"},"argument":{"name":"env","value":"pr"}}`)
+	if got := values(c); !reflect.DeepEqual(got, []string{"preview", "prod"}) || c["hasMore"] != false {
+		t.Errorf("Unexpected prompt completion: %v", c)
+	}
+
+	c = complete(`{"ref":{"type":"ref/tool","name":"deploy"},"argument":{"name":"env","value":"s"}}`)
+	if got := values(c); !reflect.DeepEqual(got, []string{"staging"}) {
+		t.Errorf("Unexpected tool completion: %v", c)
+	}
+
+	// Completion tools are subject to the caller's policies
+	c = complete(`{"ref":{"type":"ref/tool","name":"leak"},"argument":{"name":"x","value":""}}`)
+	if got := values(c); len(got) != 0 {
+		t.Errorf("Expected no values from a denied tool, got %v", got)
+	}
+
+	// Completion tools must be read-only, and their executions are audited
+	c = complete(`{"ref":{"type":"ref/tool","name":"checkout"},"argument":{"name":"branch","value":"r"}}`)
+	if got := values(c); !reflect.DeepEqual(got, []string{"release"}) {
+		t.Errorf("Unexpected completion from a read-only tool: %v", c)
+	}
+	c = complete(`{"ref":{"type":"ref/tool","name":"checkout"},"argument":{"name":"new","value":""}}`)
+	if got := values(c); len(got) != 0 {
+		t.Errorf("Expected no values from a tool with side effects, got %v", got)
+	}
+	data, _ := os.ReadFile(auditFile)
+	if !strings.Contains(string(data), `"name":"list-branches"`) || strings.Contains(string(data), `"name":"create-branch"`) {
+		t.Errorf("Expected only the read-only completion tool in the audit log, got %s", data)
+	}
+
+	if resp := complete(`{"ref":{"type":"ref/bogus"},"argument":{"name":"x"}}`); resp["error"] == nil {
+		t.Errorf("Expected an error for an unknown ref type")
+	}
+}
@@ -0,0 +1,108 @@
+package mcp
+
+import (
+	"devtool/config"
+	"devtool/tools"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type Content struct {
+	Type     string            `json:"type"` // "text", "image", "audio" or "resource"
+	Text     string            `json:"text,omitempty"`
+	Data     string            `json:"data,omitempty"` // Base64, for images and audio
+	MimeType string            `json:"mimeType,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+// MarshalJSON always includes the text of text content, even when empty.
+func (c Content) MarshalJSON() ([]byte, error) {
+	if c.Type == "text" {
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}{c.Type, c.Text})
+	}
+	type plain Content
+	return json.Marshal(plain(c))
+}
+
+// textual reports whether a MIME type is text that clients can read as is.
+func textual(mimeType string) bool {
+	switch {
+	case mimeType == "", strings.HasPrefix(mimeType, "text/"):
+		return true
+	case strings.HasSuffix(mimeType, "+json"), strings.HasSuffix(mimeType, "+xml"):
+		return true
+	}
+	switch mimeType {
+	case "application/json", "application/xml", "application/javascript", "application/yaml",
+		"application/x-yaml", "application/x-ndjson", "application/graphql":
+		return true
+	}
+	return false
+}
+
+// outputType returns the declared output type of a tool, or infers it from
+// the MIME type of its output.
+func outputType(tool config.ToolConfig, mimeType string) string {
+	if tool.Output != nil && tool.Output.Type != "" {
+		return tool.Output.Type
+	}
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return "image"
+	case strings.HasPrefix(mimeType, "audio/"):
+		return "audio"
+	case textual(mimeType):
+		return "text"
+	default:
+		return "resource"
+	}
+}
+
+// toolContent converts the output of a successful tool call into content of
+// its output type. JSON output, like the structured content of imported MCP
+// tools, is also returned as structured content.
+// Binary output can't be truncated, so it fails if it exceeds the limit.
+func toolContent(tool config.ToolConfig, res *tools.Result, version string) (Content, interface{}, error) {
+	typ := outputType(tool, res.MimeType)
+	if typ != "text" && typ != "json" && res.Truncated {
+		return Content{}, nil, fmt.Errorf("%s output exceeds max_output_bytes (%d) and can't be truncated", typ, tool.MaxOutputBytes)
+	}
+
+	switch typ {
+	case "text":
+		return Content{Type: "text", Text: res.Output}, res.Structured, nil
+	case "json":
+		var v interface{}
+		if err := json.Unmarshal([]byte(res.Output), &v); err != nil {
+			return Content{}, nil, fmt.Errorf("output is not valid JSON: %w", err)
+		}
+		// Structured content must be an object
+		if _, ok := v.(map[string]interface{}); !ok {
+			v = map[string]interface{}{"result": v}
+		}
+		return Content{Type: "text", Text: res.Output}, v, nil
+	case "image", "audio":
+		// Audio content only exists since 2025-03-26
+		if typ == "image" || version >= "2025-03-26" {
+			data := base64.StdEncoding.EncodeToString([]byte(res.Output))
+			return Content{Type: typ, Data: data, MimeType: res.MimeType}, nil, nil
+		}
+		fallthrough
+	case "resource":
+		contents := &ResourceContents{URI: "devtool://tools/" + tool.Name + "/output", MimeType: res.MimeType}
+		if textual(res.MimeType) && utf8.ValidString(res.Output) {
+			contents.Text = res.Output
+		} else {
+			contents.Blob = base64.StdEncoding.EncodeToString([]byte(res.Output))
+		}
+		return Content{Type: "resource", Resource: contents}, nil, nil
+	default:
+		return Content{}, nil, fmt.Errorf("unknown output type '%s' (expected text, json, image, audio or resource)", typ)
+	}
+}
@@ -0,0 +1,93 @@
+package mcp
+
+import (
+	"devtool/config"
+	"devtool/tools"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestToolContent(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n"
+	pngData := base64.StdEncoding.EncodeToString([]byte(png))
+	typed := func(typ string) config.ToolConfig {
+		return config.ToolConfig{Name: "t", Output: &config.OutputConfig{Type: typ}}
+	}
+
+	tests := []struct {
+		name       string
+		tool       config.ToolConfig
+		res        tools.Result
+		version    string
+		content    Content
+		structured interface{}
+		fails      bool
+	}{
+		{"untyped text", config.ToolConfig{Name: "t"}, tools.Result{Output: "hi"}, "2025-06-18",
+			Content{Type: "text", Text: "hi"}, nil, false},
+		{"JSON body stays text", config.ToolConfig{Name: "t"}, tools.Result{Output: `{"a":1}`, MimeType: "application/json"}, "2025-06-18",
+			Content{Type: "text", Text: `{"a":1}`}, nil, false},
+		{"image by Content-Type", config.ToolConfig{Name: "t"}, tools.Result{Output: png, MimeType: "image/png"}, "2025-06-18",
+			Content{Type: "image", Data: pngData, MimeType: "image/png"}, nil, false},
+		{"PDF by Content-Type", config.ToolConfig{Name: "report"}, tools.Result{Output: "%PDF", MimeType: "application/pdf"}, "2025-06-18",
+			Content{Type: "resource", Resource: &ResourceContents{URI: "devtool://tools/report/output", MimeType: "application/pdf", Blob: base64.StdEncoding.EncodeToString([]byte("%PDF"))}}, nil, false},
+		{"declared text resource", typed("resource"), tools.Result{Output: "a,b", MimeType: "text/csv"}, "2025-06-18",
+			Content{Type: "resource", Resource: &ResourceContents{URI: "devtool://tools/t/output", MimeType: "text/csv", Text: "a,b"}}, nil, false},
+		{"declared JSON object", typed("json"), tools.Result{Output: `{"a":1}`}, "2025-06-18",
+			Content{Type: "text", Text: `{"a":1}`}, map[string]interface{}{"a": float64(1)}, false},
+		{"declared JSON array", typed("json"), tools.Result{Output: `[1]`}, "2025-06-18",
+			Content{Type: "text", Text: `[1]`}, map[string]interface{}{"result": []interface{}{float64(1)}}, false},
+		{"invalid JSON", typed("json"), tools.Result{Output: `nope`}, "2025-06-18", Content{}, nil, true},
+		{"audio", typed("audio"), tools.Result{Output: "RIFF", MimeType: "audio/wav"}, "2025-03-26",
+			Content{Type: "audio", Data: base64.StdEncoding.EncodeToString([]byte("RIFF")), MimeType: "audio/wav"}, nil, false},
+		{"audio before 2025-03-26", typed("audio"), tools.Result{Output: "RIFF", MimeType: "audio/wav"}, "2024-11-05",
+			Content{Type: "resource", Resource: &ResourceContents{URI: "devtool://tools/t/output", MimeType: "audio/wav", Blob: base64.StdEncoding.EncodeToString([]byte("RIFF"))}}, nil, false},
+		{"truncated image", typed("image"), tools.Result{Output: png, MimeType: "image/png", Truncated: true}, "2025-06-18", Content{}, nil, true},
+		{"unknown type", typed("video"), tools.Result{Output: "x"}, "2025-06-18", Content{}, nil, true},
+	}
+	for _, tt := range tests {
+		content, structured, err := toolContent(tt.tool, &tt.res, tt.version)
+		if (err != nil) != tt.fails {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if tt.fails {
+			continue
+		}
+		if !reflect.DeepEqual(content, tt.content) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.content, content)
+		}
+		if !reflect.DeepEqual(structured, tt.structured) {
+			t.Errorf("%s: expected structured %v, got %v", tt.name, tt.structured, structured)
+		}
+	}
+}
+
+func TestToolCallImage(t *testing.T) {
+	s := NewServer(&config.Config{
+		Tools: []config.ToolConfig{
+			{Name: "graph", Type: "shell", Command: `printf '\211PNG\r\n\032\n'`, Output: &config.OutputConfig{Type: "image"}},
+			{Name: "empty", Type: "shell", Command: "true"},
+		},
+	}, "")
+	sess, msgs := testSession(t)
+
+	resp := call(t, s, sess, msgs, "tools/call", `{"name":"graph","arguments":{}}`)
+	result := resp["result"].(map[string]interface{})
+	image := result["content"].([]interface{})[0].(map[string]interface{})
+	if image["type"] != "image" || image["mimeType"] != "image/png" || image["data"] != base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\n")) {
+		t.Errorf("Unexpected image content: %v", image)
+	}
+	if _, ok := result["structuredContent"].(map[string]interface{})["stdout"]; ok {
+		t.Errorf("Binary stdout should not be in structured content: %v", result["structuredContent"])
+	}
+
+	// Empty text output still has a text field
+	resp = call(t, s, sess, msgs, "tools/call", `{"name":"empty","arguments":{}}`)
+	raw, _ := json.Marshal(resp["result"].(map[string]interface{})["content"])
+	if string(raw) != `[{"text":"","type":"text"}]` {
+		t.Errorf("Unexpected content for empty output: %s", raw)
+	}
+}
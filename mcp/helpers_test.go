@@ -0,0 +1,169 @@
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// localIdentity is the identity of test sessions, as for Stdio.
+var localIdentity = Identity{Name: "local", Method: "none", Transport: "stdio"}
+
+// pipeStream serves a session over in-memory pipes. It returns a function
+// that sends one line and a channel of every line the server writes.
+func pipeStream(t *testing.T, s *Server) (send func(line string), out <-chan string) {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	go func() {
+		s.serveStream(inR, outW, localIdentity)
+		outW.Close()
+	}()
+	t.Cleanup(func() { inW.Close() })
+
+	lines := make(chan string, 16)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	return func(line string) { io.WriteString(inW, line+"\n") }, lines
+}
+
+// isNotification reports whether line holds a server notification or
+// request rather than a response or batch of responses.
+func isNotification(line string) bool {
+	var msg struct {
+		Method string `json:"method"`
+	}
+	return json.Unmarshal([]byte(line), &msg) == nil && msg.Method != ""
+}
+
+// rawStream serves a session over pipes. next returns the next response or
+// batch of responses as raw JSON, skipping server notifications.
+func rawStream(t *testing.T, s *Server) (send func(line string), next func() string) {
+	t.Helper()
+	send, out := pipeStream(t, s)
+	next = func() string {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case line := <-out:
+				if !isNotification(line) {
+					return line
+				}
+			case <-timeout:
+				t.Fatal("Timed out waiting for a response")
+				return ""
+			}
+		}
+	}
+	return send, next
+}
+
+// testStream serves a session over pipes and returns a function that sends
+// one request and returns its response, skipping notifications.
+func testStream(t *testing.T, s *Server) func(line string) map[string]interface{} {
+	t.Helper()
+	send, next := rawStream(t, s)
+	return func(line string) map[string]interface{} {
+		t.Helper()
+		send(line)
+		var msg map[string]interface{}
+		json.Unmarshal([]byte(next()), &msg)
+		return msg
+	}
+}
+
+// testSession returns an initialized session whose messages are decoded
+// onto a channel, for requests handled with call.
+func testSession(t *testing.T) (*session, <-chan map[string]interface{}) {
+	t.Helper()
+	r, w := io.Pipe()
+	t.Cleanup(func() { w.Close() })
+
+	msgs := make(chan map[string]interface{}, 16)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			var msg map[string]interface{}
+			json.Unmarshal(scanner.Bytes(), &msg)
+			msgs <- msg
+		}
+	}()
+	sess := newSession(w, localIdentity)
+	sess.initialize(LatestProtocolVersion, nil, Implementation{Name: "test"})
+	return sess, msgs
+}
+
+// call handles one request for sess and returns the next message it gets.
+func call(t *testing.T, s *Server, sess *session, msgs <-chan map[string]interface{}, method, params string) map[string]interface{} {
+	t.Helper()
+	go s.handleRequest(sess, JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: json.RawMessage(params)})
+	select {
+	case msg := <-msgs:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for %s", method)
+		return nil
+	}
+}
+
+func errorCode(resp map[string]interface{}) float64 {
+	if e, ok := resp["error"].(map[string]interface{}); ok {
+		return e["code"].(float64)
+	}
+	return 0
+}
+
+// authenticatePipe runs authenticate on one end of a pipe after the client
+// sends line, returning the server's reply and the resulting identity.
+func authenticatePipe(t *testing.T, s *Server, line string) (JSONRPCResponse, Identity, error) {
+	t.Helper()
+	client, server := net.Pipe()
+	defer client.Close()
+
+	type result struct {
+		id  Identity
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		_, id, err := s.authenticate(server)
+		server.Close()
+		done <- result{id, err}
+	}()
+
+	client.Write([]byte(line + "\n"))
+	var resp JSONRPCResponse
+	if scanner := bufio.NewScanner(client); scanner.Scan() {
+		json.Unmarshal(scanner.Bytes(), &resp)
+	}
+	r := <-done
+	return resp, r.id, r.err
+}
+
+// waitImported waits until s has imported the tools of its MCP servers.
+func waitImported(t *testing.T, s *Server) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.RLock()
+		done := s.toolsConfig == s.Config
+		s.mu.RUnlock()
+		if done {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the MCP tools to be imported")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,135 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Standard JSON-RPC 2.0 error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// errorResponse builds an error response. data may be nil.
+func errorResponse(id interface{}, code int, msg string, data interface{}) *JSONRPCResponse {
+	return &JSONRPCResponse{JSONRPC: "2.0", ID: id, Error: &JSONRPCError{Code: code, Message: msg, Data: data}}
+}
+
+// parseMessage validates one JSON-RPC message, which must be a JSON object.
+// Invalid messages yield an Invalid Request response, echoing the ID if it
+// could be read.
+func parseMessage(raw json.RawMessage) (*message, *JSONRPCResponse) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return nil, errorResponse(nil, codeInvalidRequest, "Invalid Request", "message must be a JSON object")
+	}
+	msg := &message{Params: fields["params"], Result: fields["result"]}
+
+	rawID, hasID := fields["id"]
+	if hasID {
+		dec := json.NewDecoder(bytes.NewReader(rawID))
+		dec.UseNumber() // Echo numeric IDs exactly
+		dec.Decode(&msg.ID)
+		switch msg.ID.(type) {
+		case string, json.Number:
+		case nil:
+			// Only error responses to unparseable messages lack an ID
+			if fields["error"] == nil || fields["method"] != nil {
+				return nil, errorResponse(nil, codeInvalidRequest, "Invalid Request", "id must not be null")
+			}
+		default:
+			return nil, errorResponse(nil, codeInvalidRequest, "Invalid Request", "id must be a string or a number")
+		}
+	}
+	invalid := func(reason string) (*message, *JSONRPCResponse) {
+		return nil, errorResponse(msg.ID, codeInvalidRequest, "Invalid Request", reason)
+	}
+
+	if err := json.Unmarshal(fields["jsonrpc"], &msg.JSONRPC); err != nil || msg.JSONRPC != "2.0" {
+		return invalid(`jsonrpc must be "2.0"`)
+	}
+
+	if rawMethod, ok := fields["method"]; ok {
+		if err := json.Unmarshal(rawMethod, &msg.Method); err != nil || msg.Method == "" {
+			return invalid("method must be a non-empty string")
+		}
+		if p := bytes.TrimSpace(msg.Params); len(p) > 0 && p[0] != '{' && p[0] != '[' {
+			return invalid("params must be an object or an array")
+		}
+		return msg, nil
+	}
+
+	// Without a method it must be a response to a server request
+	if rawErr, ok := fields["error"]; ok {
+		if err := json.Unmarshal(rawErr, &msg.Error); err != nil || msg.Error == nil {
+			return invalid("error must be an object")
+		}
+	}
+	if (msg.Result == nil) == (msg.Error == nil) || !hasID {
+		return invalid("message must be a request, a notification or a response")
+	}
+	return msg, nil
+}
+
+func (m *message) request() JSONRPCRequest {
+	return JSONRPCRequest{JSONRPC: m.JSONRPC, Method: m.Method, Params: m.Params, ID: m.ID}
+}
+
+// handleBatch answers a batch with an array holding one response per
+// request, in no particular order. Requests in a batch are handled
+// concurrently; a batch of notifications and responses gets no reply.
+func (s *Server) handleBatch(sess *session, items []json.RawMessage) {
+	responses := make([]*JSONRPCResponse, len(items))
+	var wg sync.WaitGroup
+	for i, raw := range items {
+		msg, errResp := parseMessage(raw)
+		switch {
+		case errResp != nil:
+			responses[i] = errResp
+		case msg.Method == "":
+			if !sess.deliver(msg) {
+				sess.log.Error("Received response for unknown request %v", msg.ID)
+			}
+		case msg.Method == "initialize":
+			if msg.ID != nil {
+				responses[i] = errorResponse(msg.ID, codeInvalidRequest, "Invalid Request", "initialize must not be part of a batch")
+			}
+		default:
+			wg.Add(1)
+			go func(i int, req JSONRPCRequest) {
+				defer wg.Done()
+				responses[i] = s.dispatch(sess, req)
+			}(i, msg.request())
+		}
+	}
+	wg.Wait()
+
+	batch := make([]*JSONRPCResponse, 0, len(responses))
+	for _, resp := range responses {
+		if resp != nil {
+			batch = append(batch, resp)
+		}
+	}
+	if len(batch) == 0 {
+		return
+	}
+	if err := sess.send(batch); err != nil {
+		sess.log.Error("Failed to send batch response: %v", err)
+	}
+}
+
+// checkBatch refuses batches in sessions that negotiated 2025-06-18 or
+// later, which removed the batching added in 2025-03-26. Earlier sessions
+// get plain JSON-RPC 2.0 semantics.
+func checkBatch(version string) error {
+	if version >= "2025-06-18" {
+		return fmt.Errorf("batches are not supported in protocol version %s", version)
+	}
+	return nil
+}
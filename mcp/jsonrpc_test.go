@@ -0,0 +1,177 @@
+package mcp
+
+import (
+	"devtool/config"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type rpcResponse struct {
+	ID     interface{}     `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *JSONRPCError   `json:"error"`
+}
+
+func conformanceServer() *Server {
+	return NewServer(&config.Config{
+		Tools: []config.ToolConfig{{Name: "hello", Type: "shell", Command: "echo hi"}},
+	}, "")
+}
+
+func TestJSONRPCErrors(t *testing.T) {
+	send, next := rawStream(t, conformanceServer())
+	send(`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	next()
+
+	tests := []struct {
+		name    string
+		line    string
+		code    int
+		id      interface{}
+		hasData bool
+	}{
+		{"invalid JSON", `{"jsonrpc":"2.0","method":"ping",`, codeParseError, nil, true},
+		{"not an object", `"hello"`, codeInvalidRequest, nil, true},
+		{"wrong version", `{"jsonrpc":"1.0","id":1,"method":"ping"}`, codeInvalidRequest, float64(1), true},
+		{"missing version", `{"id":2,"method":"ping"}`, codeInvalidRequest, float64(2), true},
+		{"method not a string", `{"jsonrpc":"2.0","id":3,"method":5}`, codeInvalidRequest, float64(3), true},
+		{"object ID", `{"jsonrpc":"2.0","id":{"a":1},"method":"ping"}`, codeInvalidRequest, nil, true},
+		{"null ID", `{"jsonrpc":"2.0","id":null,"method":"ping"}`, codeInvalidRequest, nil, true},
+		{"scalar params", `{"jsonrpc":"2.0","id":4,"method":"ping","params":"x"}`, codeInvalidRequest, float64(4), true},
+		{"neither request nor response", `{"jsonrpc":"2.0","id":5}`, codeInvalidRequest, float64(5), true},
+		{"unknown method", `{"jsonrpc":"2.0","id":"six","method":"no/such"}`, codeMethodNotFound, "six", true},
+		{"invalid tool params", `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":5}}`, codeInvalidParams, float64(7), true},
+		{"missing tool name", `{"jsonrpc":"2.0","id":8,"method":"tools/call","params":{}}`, codeInvalidParams, float64(8), true},
+		{"unknown tool", `{"jsonrpc":"2.0","id":9,"method":"tools/call","params":{"name":"missing"}}`, codeInvalidParams, float64(9), false},
+	}
+	for _, tt := range tests {
+		send(tt.line)
+		var resp rpcResponse
+		raw := next()
+		if err := json.Unmarshal([]byte(raw), &resp); err != nil {
+			t.Errorf("%s: invalid response %s", tt.name, raw)
+			continue
+		}
+		if resp.Error == nil || resp.Error.Code != tt.code {
+			t.Errorf("%s: expected error %d, got %s", tt.name, tt.code, raw)
+			continue
+		}
+		if resp.ID != tt.id || !strings.Contains(raw, `"id":`) {
+			t.Errorf("%s: expected id %v, got %s", tt.name, tt.id, raw)
+		}
+		if (resp.Error.Data != nil) != tt.hasData {
+			t.Errorf("%s: expected data=%v, got %s", tt.name, tt.hasData, raw)
+		}
+	}
+}
+
+func TestJSONRPCNotificationsAndIDs(t *testing.T) {
+	send, next := rawStream(t, conformanceServer())
+	send(`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	next()
+
+	// Neither unknown notifications nor requests sent without an ID get a
+	// response, and the tool is not run
+	send(`{"jsonrpc":"2.0","method":"notifications/unknown"}`)
+	send(`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"hello"}}`)
+	send(`{"jsonrpc":"2.0","id":12345678901234567890,"method":"ping"}`)
+	if got := next(); got != `{"jsonrpc":"2.0","result":{},"id":12345678901234567890}` {
+		t.Errorf("Expected only the ping response with its exact ID, got %s", got)
+	}
+
+	send(`{"jsonrpc":"2.0","id":"abc","method":"ping"}`)
+	if got := next(); !strings.Contains(got, `"id":"abc"`) {
+		t.Errorf("Expected string ID to be echoed, got %s", got)
+	}
+
+	// Responses to unknown server requests are dropped
+	send(`{"jsonrpc":"2.0","id":"devtool-99","result":{}}`)
+	send(`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error"}}`)
+	send(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if got := next(); !strings.Contains(got, `"id":1`) {
+		t.Errorf("Expected the ping response, got %s", got)
+	}
+}
+
+func TestJSONRPCBatch(t *testing.T) {
+	send, next := rawStream(t, conformanceServer())
+
+	// Pings can be batched before initialize; other requests are rejected
+	send(`[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","id":2,"method":"tools/list"}]`)
+	var batch []rpcResponse
+	json.Unmarshal([]byte(next()), &batch)
+	if len(batch) != 2 {
+		t.Fatalf("Expected 2 responses, got %v", batch)
+	}
+	for _, resp := range batch {
+		if (resp.ID == float64(2)) != (resp.Error != nil) {
+			t.Errorf("Unexpected response before initialize: %+v", resp)
+		}
+	}
+
+	send(`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	next()
+
+	send("[" + strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"ping"}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"foo":"bar"}`,
+		`{"jsonrpc":"2.0","id":"b","method":"tools/call","params":{"name":"hello","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"no/such"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`,
+	}, ",") + "]")
+	raw := next()
+	batch = nil
+	if err := json.Unmarshal([]byte(raw), &batch); err != nil {
+		t.Fatalf("Expected a batch response, got %s", raw)
+	}
+	byID := map[interface{}]rpcResponse{}
+	for _, resp := range batch {
+		byID[resp.ID] = resp
+	}
+	if len(batch) != 5 || len(byID) != 5 {
+		t.Fatalf("Expected 5 responses with distinct IDs, got %s", raw)
+	}
+	if r := byID[float64(1)]; r.Error != nil {
+		t.Errorf("Expected ping to succeed: %s", raw)
+	}
+	if r := byID[nil]; r.Error == nil || r.Error.Code != codeInvalidRequest {
+		t.Errorf("Expected -32600 for the invalid entry: %s", raw)
+	}
+	if r := byID["b"]; r.Error != nil || !strings.Contains(string(r.Result), "hi") {
+		t.Errorf("Expected the tool call to succeed: %s", raw)
+	}
+	if r := byID[float64(3)]; r.Error == nil || r.Error.Code != codeMethodNotFound {
+		t.Errorf("Expected -32601 for the unknown method: %s", raw)
+	}
+	if r := byID[float64(4)]; r.Error == nil || r.Error.Code != codeInvalidRequest {
+		t.Errorf("Expected initialize to be refused in a batch: %s", raw)
+	}
+
+	// Batches of notifications get no response
+	send(`[{"jsonrpc":"2.0","method":"notifications/initialized"}]`)
+	send(`[]`)
+	if got := next(); !strings.Contains(got, `"code":-32600`) || strings.HasPrefix(got, "[") {
+		t.Errorf("Expected a single -32600 for an empty batch, got %s", got)
+	}
+	send(`[1,2]`)
+	batch = nil
+	json.Unmarshal([]byte(next()), &batch)
+	if len(batch) != 2 || batch[0].Error == nil || batch[0].Error.Code != codeInvalidRequest {
+		t.Errorf("Expected two -32600 responses, got %+v", batch)
+	}
+}
+
+func TestJSONRPCBatchRemovedIn20250618(t *testing.T) {
+	send, next := rawStream(t, conformanceServer())
+	send(`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`)
+	next()
+
+	send(`[{"jsonrpc":"2.0","id":1,"method":"ping"}]`)
+	var resp rpcResponse
+	raw := next()
+	if err := json.Unmarshal([]byte(raw), &resp); err != nil || resp.Error == nil || resp.Error.Code != codeInvalidRequest {
+		t.Errorf("Expected batches to be refused, got %s", raw)
+	}
+}
@@ -0,0 +1,114 @@
+package mcp
+
+import (
+	"devtool/tools"
+	"encoding/json"
+	"fmt"
+)
+
+// Version is the devtool version reported in serverInfo. Release builds set
+// it with -ldflags "-X devtool/mcp.Version=1.2.3".
+var Version = "dev"
+
+func init() {
+	// Upstream MCP servers see the same version in clientInfo
+	tools.ClientInfo.Version = Version
+}
+
+// SupportedProtocolVersions lists the MCP revisions the server speaks,
+// newest first.
+var SupportedProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// LatestProtocolVersion is offered to clients requesting an unknown revision.
+var LatestProtocolVersion = SupportedProtocolVersions[0]
+
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type InitializeParams struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ClientInfo      Implementation         `json:"clientInfo"`
+}
+
+type InitializeResult struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ServerInfo      Implementation         `json:"serverInfo"`
+	Instructions    string                 `json:"instructions,omitempty"`
+}
+
+// negotiateVersion answers a requested protocol version: the same version
+// if the server supports it, otherwise the latest one it supports. The
+// client disconnects if it can't speak the answer.
+func negotiateVersion(requested string) string {
+	for _, v := range SupportedProtocolVersions {
+		if v == requested {
+			return v
+		}
+	}
+	return LatestProtocolVersion
+}
+
+// serverCapabilities returns the capabilities advertised for a protocol
+// version. Completions only exist since 2025-03-26.
+func serverCapabilities(version string) map[string]interface{} {
+	caps := map[string]interface{}{
+		"tools": map[string]interface{}{
+			"listChanged": true,
+		},
+		"logging": map[string]interface{}{},
+		"prompts": map[string]interface{}{
+			"listChanged": true,
+		},
+		"resources": map[string]interface{}{
+			"subscribe":   true,
+			"listChanged": true,
+		},
+	}
+	if version >= "2025-03-26" {
+		caps["completions"] = map[string]interface{}{}
+	}
+	return caps
+}
+
+// initialize records the client's capabilities and info on the session and
+// negotiates the protocol version. A session can only be initialized once.
+func (s *Server) initialize(sess *session, raw json.RawMessage) (*InitializeResult, error) {
+	var params InitializeParams
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidParams, err)
+		}
+	}
+	if params.ProtocolVersion == "" {
+		return nil, fmt.Errorf("%w: protocolVersion is required", errInvalidParams)
+	}
+
+	version := negotiateVersion(params.ProtocolVersion)
+	if sess.isInitialized() {
+		return nil, errAlreadyInitialized
+	}
+	// Log before initializing, so the handshake isn't forwarded to the client
+	if version != params.ProtocolVersion {
+		sess.log.Warn("Client %s requested unsupported protocol version %s, offering %s", params.ClientInfo.Name, params.ProtocolVersion, version)
+	}
+	sess.log.Info("Initialized session with %s %s (protocol %s)", params.ClientInfo.Name, params.ClientInfo.Version, version)
+	if !sess.initialize(version, params.Capabilities, params.ClientInfo) {
+		return nil, errAlreadyInitialized
+	}
+
+	return &InitializeResult{
+		ProtocolVersion: version,
+		Capabilities:    serverCapabilities(version),
+		ServerInfo:      Implementation{Name: "devtool", Version: Version},
+	}, nil
+}
+
+var (
+	// errInvalidParams is wrapped by errors reported as -32602.
+	errInvalidParams      = fmt.Errorf("invalid params")
+	errAlreadyInitialized = fmt.Errorf("session already initialized")
+)
@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"devtool/config"
+	"testing"
+)
+
+func TestInitialize(t *testing.T) {
+	s := NewServer(&config.Config{}, "")
+	send := testStream(t, s)
+
+	if resp := send(`{"jsonrpc":"2.0","id":1,"method":"ping"}`); resp["error"] != nil {
+		t.Errorf("Expected ping to work before initialize, got %v", resp)
+	}
+	if resp := send(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); errorCode(resp) != -32600 {
+		t.Errorf("Expected -32600 before initialize, got %v", resp)
+	}
+	if resp := send(`{"jsonrpc":"2.0","id":3,"method":"initialize","params":{}}`); errorCode(resp) != -32602 {
+		t.Errorf("Expected -32602 without protocolVersion, got %v", resp)
+	}
+
+	resp := send(`{"jsonrpc":"2.0","id":4,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{"elicitation":{}},"clientInfo":{"name":"test","version":"1.0"}}}`)
+	result, ok := resp["result"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("initialize failed: %v", resp)
+	}
+	if result["protocolVersion"] != "2025-03-26" {
+		t.Errorf("Expected the requested version to be accepted, got %v", result["protocolVersion"])
+	}
+	if info := result["serverInfo"].(map[string]interface{}); info["version"] != Version {
+		t.Errorf("Expected server version %s, got %v", Version, info["version"])
+	}
+	if _, ok := result["capabilities"].(map[string]interface{})["completions"]; !ok {
+		t.Errorf("Expected completions for 2025-03-26: %v", result["capabilities"])
+	}
+
+	if resp := send(`{"jsonrpc":"2.0","id":5,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`); errorCode(resp) != -32600 {
+		t.Errorf("Expected a second initialize to fail, got %v", resp)
+	}
+	if resp := send(`{"jsonrpc":"2.0","id":6,"method":"tools/list"}`); resp["error"] != nil {
+		t.Errorf("Expected tools/list to work after initialize, got %v", resp)
+	}
+}
+
+func TestInitializeNegotiation(t *testing.T) {
+	tests := []struct {
+		requested, expected string
+		completions         bool
+	}{
+		{"2025-06-18", "2025-06-18", true},
+		{"2024-11-05", "2024-11-05", false},
+		{"2099-01-01", LatestProtocolVersion, true},
+	}
+	for _, tt := range tests {
+		send := testStream(t, NewServer(&config.Config{}, ""))
+		resp := send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"` + tt.requested + `"}}`)
+		result := resp["result"].(map[string]interface{})
+		if result["protocolVersion"] != tt.expected {
+			t.Errorf("Requested %s: expected %s, got %v", tt.requested, tt.expected, result["protocolVersion"])
+		}
+		_, ok := result["capabilities"].(map[string]interface{})["completions"]
+		if ok != tt.completions {
+			t.Errorf("Requested %s: expected completions=%v, got %v", tt.requested, tt.completions, ok)
+		}
+
+		resp = send(`{"jsonrpc":"2.0","id":2,"method":"completion/complete","params":{"ref":{"type":"ref/prompt","name":"x"},"argument":{"name":"y","value":""}}}`)
+		if found := errorCode(resp) != codeMethodNotFound; found != tt.completions {
+			t.Errorf("Requested %s: expected completion/complete to exist=%v, got %v", tt.requested, tt.completions, resp)
+		}
+	}
+}
+
+func TestStructuredContentVersion(t *testing.T) {
+	s := NewServer(&config.Config{
+		Tools: []config.ToolConfig{{Name: "hello", Type: "shell", Command: "echo hi"}},
+	}, "")
+	for version, structured := range map[string]bool{"2025-06-18": true, "2025-03-26": false} {
+		send := testStream(t, s)
+		send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"` + version + `"}}`)
+		resp := send(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"hello","arguments":{}}}`)
+		_, ok := resp["result"].(map[string]interface{})["structuredContent"]
+		if ok != structured {
+			t.Errorf("Protocol %s: expected structuredContent=%v, got %v", version, structured, resp["result"])
+		}
+	}
+}
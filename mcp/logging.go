@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"devtool/logger"
+	"fmt"
+	"log/slog"
+)
+
+// MCP log levels (RFC 5424 severities) mapped to slog levels.
+var mcpLevels = []struct {
+	name  string
+	level slog.Level
+}{
+	{"debug", slog.LevelDebug},
+	{"info", slog.LevelInfo},
+	{"notice", slog.LevelInfo + 2},
+	{"warning", slog.LevelWarn},
+	{"error", slog.LevelError},
+	{"critical", slog.LevelError + 4},
+	{"alert", slog.LevelError + 8},
+	{"emergency", slog.LevelError + 12},
+}
+
+// parseMCPLevel converts an MCP level name to a slog level.
+func parseMCPLevel(name string) (slog.Level, error) {
+	for _, l := range mcpLevels {
+		if l.name == name {
+			return l.level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level '%s'", name)
+}
+
+// mcpLevel returns the MCP name of a slog level.
+func mcpLevel(level slog.Level) string {
+	name := mcpLevels[0].name
+	for _, l := range mcpLevels {
+		if level >= l.level {
+			name = l.name
+		}
+	}
+	return name
+}
+
+// forwardLogs sends the session's own log records to the client as
+// notifications/message, filtered by the level set via logging/setLevel.
+// Nothing is sent before the session is initialized.
+func (c *session) forwardLogs() (stop func()) {
+	return logger.Subscribe(func(r slog.Record) {
+		data := map[string]interface{}{"message": r.Message}
+		ours := false
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == "session" {
+				ours = a.Value.String() == c.id
+				return true
+			}
+			data[a.Key] = a.Value.Any()
+			return true
+		})
+		if !ours || r.Level < c.logLevel() || !c.isInitialized() {
+			return
+		}
+
+		c.send(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"method":  "notifications/message",
+			"params": map[string]interface{}{
+				"level":  mcpLevel(r.Level),
+				"logger": "devtool",
+				"data":   data,
+			},
+		})
+	})
+}
+
+func (c *session) setLogLevel(level slog.Level) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.minLevel = level
+}
+
+func (c *session) logLevel() slog.Level {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.minLevel
+}
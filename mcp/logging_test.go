@@ -0,0 +1,100 @@
+package mcp
+
+import (
+	"devtool/config"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMCPLevels(t *testing.T) {
+	for _, name := range []string{"debug", "info", "notice", "warning", "error", "critical", "alert", "emergency"} {
+		level, err := parseMCPLevel(name)
+		if err != nil {
+			t.Fatalf("parseMCPLevel(%q) failed: %v", name, err)
+		}
+		if got := mcpLevel(level); got != name {
+			t.Errorf("Expected %s to round-trip, got %s", name, got)
+		}
+	}
+	if got := mcpLevel(slog.LevelWarn); got != "warning" {
+		t.Errorf("Expected slog WARN to map to warning, got %s", got)
+	}
+	if _, err := parseMCPLevel("verbose"); err == nil {
+		t.Errorf("Expected an error for an unknown level")
+	}
+}
+
+func TestLoggingNotifications(t *testing.T) {
+	s := NewServer(&config.Config{
+		Tools: []config.ToolConfig{{
+			Name:    "warn",
+			Type:    "shell",
+			Command: "echo done; echo 'disk almost full' >&2",
+		}},
+	}, "")
+
+	send, out := pipeStream(t, s)
+	next := func() map[string]interface{} {
+		select {
+		case line := <-out:
+			var msg map[string]interface{}
+			json.Unmarshal([]byte(line), &msg)
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the server")
+			return nil
+		}
+	}
+
+	send(`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`)
+	if resp := next(); resp["id"] == nil {
+		t.Fatalf("Expected no notifications before the initialize response, got %v", resp)
+	}
+	send(`{"jsonrpc":"2.0","id":1,"method":"logging/setLevel","params":{"level":"bogus"}}`)
+	if resp := next(); resp["error"] == nil {
+		t.Fatalf("Expected an error for an invalid level, got %v", resp)
+	}
+
+	send(`{"jsonrpc":"2.0","id":2,"method":"logging/setLevel","params":{"level":"info"}}`)
+	if resp := next(); resp["error"] != nil {
+		t.Fatalf("setLevel failed: %v", resp)
+	}
+
+	send(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"warn","arguments":{}}}`)
+
+	var sawStderr bool
+	for {
+		msg := next()
+		if msg["id"] != nil {
+			break
+		}
+		if msg["method"] != "notifications/message" {
+			t.Fatalf("Unexpected message: %v", msg)
+		}
+		params := msg["params"].(map[string]interface{})
+		data := params["data"].(map[string]interface{})
+		if _, ok := data["session"]; ok {
+			t.Errorf("Session ID should not be forwarded: %v", data)
+		}
+		if data["stream"] == "stderr" && strings.Contains(data["message"].(string), "disk almost full") {
+			sawStderr = true
+			if params["level"] != "info" || data["tool"] != "warn" {
+				t.Errorf("Unexpected stderr notification: %v", params)
+			}
+		}
+	}
+	if !sawStderr {
+		t.Errorf("Expected tool stderr to be forwarded")
+	}
+
+	// Above the requested level nothing is forwarded
+	send(`{"jsonrpc":"2.0","id":4,"method":"logging/setLevel","params":{"level":"error"}}`)
+	next()
+	send(`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"warn","arguments":{}}}`)
+	if msg := next(); msg["id"] == nil {
+		t.Errorf("Expected only the response at level error, got %v", msg)
+	}
+}
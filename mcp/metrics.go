@@ -0,0 +1,63 @@
+package mcp
+
+import (
+	"devtool/audit"
+	"devtool/config"
+	"devtool/metrics"
+	"devtool/tracing"
+	"errors"
+	"time"
+)
+
+var (
+	requestsTotal = metrics.NewCounter("devtool_mcp_requests_total",
+		"MCP requests and notifications received, by method.", "method")
+	toolCallsTotal = metrics.NewCounter("devtool_tool_calls_total",
+		"tools/call requests by tool or workflow name and outcome (ok, error or denied).", "name", "outcome")
+	toolCallSeconds = metrics.NewHistogram("devtool_tool_call_duration_seconds",
+		"Duration of tools/call requests, including approval.", "name")
+	activeSessions = metrics.NewGauge("devtool_active_sessions",
+		"Connected MCP sessions.")
+	configReloadsTotal = metrics.NewCounter("devtool_config_reloads_total",
+		"Config reloads by result (success or failure).", "result")
+)
+
+// knownMethods bounds the method label; anything else counts as "other".
+var knownMethods = map[string]bool{
+	"initialize":                true,
+	"notifications/initialized": true,
+	"ping":                      true,
+	"logging/setLevel":          true,
+	"tools/list":                true,
+	"tools/call":                true,
+	"resources/list":            true,
+	"resources/templates/list":  true,
+	"resources/read":            true,
+	"resources/subscribe":       true,
+	"resources/unsubscribe":     true,
+	"prompts/list":              true,
+	"prompts/get":               true,
+	"completion/complete":       true,
+	"devtool/toolsets/list":     true,
+	"devtool/toolsets/select":   true,
+}
+
+func methodLabel(method string) string {
+	if knownMethods[method] {
+		return method
+	}
+	return "other"
+}
+
+// recordCall writes a finished or refused tools/call to the audit log, the
+// call metrics and its trace span.
+func recordCall(span *tracing.Span, rec audit.Record, params []config.Parameter, start time.Time) {
+	rec.DurationMs = time.Since(start).Milliseconds()
+	audit.Log(rec, params)
+	span.SetAttribute("devtool.outcome", rec.Status)
+	if rec.Error != "" {
+		span.RecordError(errors.New(rec.Error))
+	}
+	toolCallsTotal.Inc(rec.Name, rec.Status)
+	toolCallSeconds.Observe(time.Since(start).Seconds(), rec.Name)
+}
@@ -0,0 +1,110 @@
+package mcp
+
+import (
+	"devtool/config"
+	"path"
+)
+
+// Error code returned when a policy denies a tool call
+const codeForbidden = -32003
+
+// isAllowed reports whether the policies grant id access to the tool or
+// workflow called name. Without policies everything is allowed.
+func isAllowed(policies []config.PolicyConfig, id Identity, name string, tags []string) bool {
+	if len(policies) == 0 {
+		return true
+	}
+
+	for _, p := range policies {
+		if !matchAny(p.Identities, id.Name) {
+			continue
+		}
+		if matchAny(p.Allow, name) {
+			return true
+		}
+		for _, tag := range tags {
+			for _, allowed := range p.Tags {
+				if tag == allowed {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// workflowAllowed reports whether the policies grant id access to a
+// workflow and to the tool of every step, so that workflows can't be used to
+// run tools the caller may not call directly.
+func workflowAllowed(policies []config.PolicyConfig, id Identity, wf config.WorkflowConfig, tools []config.ToolConfig) bool {
+	if !isAllowed(policies, id, wf.Name, wf.Tags) {
+		return false
+	}
+	for _, step := range wf.Steps {
+		var tags []string
+		for _, t := range tools {
+			if t.Name == step.Tool {
+				tags = t.Tags
+				break
+			}
+		}
+		if !isAllowed(policies, id, step.Tool, tags) {
+			return false
+		}
+	}
+	return true
+}
+
+// promptAllowed reports whether the policies grant id access to every tool
+// or workflow a prompt embeds, so that prompts/list only shows prompts the
+// caller can get.
+func promptAllowed(policies []config.PolicyConfig, id Identity, p config.PromptConfig, cfg *config.Config) bool {
+	for _, m := range p.Messages {
+		if m.Tool != "" && !nameAllowed(policies, id, m.Tool, cfg) {
+			return false
+		}
+	}
+	return true
+}
+
+// nameAllowed reports whether the policies grant id access to the tool or
+// workflow called name, checking every step of workflows.
+func nameAllowed(policies []config.PolicyConfig, id Identity, name string, cfg *config.Config) bool {
+	for _, t := range cfg.Tools {
+		if t.Name == name {
+			return isAllowed(policies, id, name, t.Tags)
+		}
+	}
+	for _, wf := range cfg.Workflows {
+		if wf.Name == name {
+			return workflowAllowed(policies, id, wf, cfg.Tools)
+		}
+	}
+	return isAllowed(policies, id, name, nil)
+}
+
+// allowedResources returns the resources the policies grant id access to.
+// Command resources run shell commands, so like tools they are matched by
+// name; files and globs are readable by everyone.
+func allowedResources(policies []config.PolicyConfig, id Identity, resources []config.ResourceConfig) []config.ResourceConfig {
+	if len(policies) == 0 {
+		return resources
+	}
+	allowed := make([]config.ResourceConfig, 0, len(resources))
+	for _, r := range resources {
+		if r.Command == "" || isAllowed(policies, id, r.Name, nil) {
+			allowed = append(allowed, r)
+		}
+	}
+	return allowed
+}
+
+// matchAny reports whether s matches one of the glob patterns.
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package mcp
+
+import (
+	"devtool/config"
+	"testing"
+)
+
+func TestIsAllowed(t *testing.T) {
+	policies := []config.PolicyConfig{
+		{Identities: []string{"ci-*"}, Allow: []string{"get-*", "list-files"}},
+		{Identities: []string{"alice"}, Tags: []string{"db"}},
+		{Identities: []string{"*"}, Allow: []string{"ping"}},
+	}
+
+	cases := []struct {
+		identity string
+		name     string
+		tags     []string
+		want     bool
+	}{
+		{"ci-bot", "get-status", nil, true},
+		{"ci-bot", "list-files", nil, true},
+		{"ci-bot", "backup-db", []string{"db"}, false},
+		{"alice", "backup-db", []string{"ops", "db"}, true},
+		{"alice", "get-status", nil, false},
+		{"local", "ping", nil, true},
+		{"local", "get-status", nil, false},
+	}
+	for _, c := range cases {
+		got := isAllowed(policies, Identity{Name: c.identity}, c.name, c.tags)
+		if got != c.want {
+			t.Errorf("isAllowed(%s, %s) = %v, want %v", c.identity, c.name, got, c.want)
+		}
+	}
+
+	if !isAllowed(nil, Identity{Name: "anyone"}, "anything", nil) {
+		t.Error("Expected everything to be allowed without policies")
+	}
+}
+
+func TestWorkflowAllowed(t *testing.T) {
+	policies := []config.PolicyConfig{
+		{Identities: []string{"ci-bot"}, Allow: []string{"release", "get-*"}},
+	}
+	tools := []config.ToolConfig{
+		{Name: "get-version"},
+		{Name: "deploy", Tags: []string{"ops"}},
+	}
+
+	safe := config.WorkflowConfig{Name: "release", Steps: []config.StepConfig{{Name: "v", Tool: "get-version"}}}
+	if !workflowAllowed(policies, Identity{Name: "ci-bot"}, safe, tools) {
+		t.Error("Expected a workflow of allowed steps to be allowed")
+	}
+
+	risky := safe
+	risky.Steps = append(risky.Steps, config.StepConfig{Name: "d", Tool: "deploy"})
+	if workflowAllowed(policies, Identity{Name: "ci-bot"}, risky, tools) {
+		t.Error("Expected a workflow with a denied step to be denied")
+	}
+}
+
+func TestCallDenied(t *testing.T) {
+	s := NewServer(&config.Config{
+		Tools: []config.ToolConfig{
+			{Name: "hello", Type: "shell", Command: "echo hi"},
+			{Name: "deploy", Type: "shell", Command: "echo deployed"},
+		},
+		Workflows: []config.WorkflowConfig{
+			{Name: "ship", Steps: []config.StepConfig{{Name: "d", Tool: "deploy"}}},
+		},
+		Policies: []config.PolicyConfig{{Identities: []string{"local"}, Allow: []string{"hello", "ship", "get-*"}}},
+	}, "")
+	send := testStream(t, s)
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`)
+
+	// Names that aren't granted are denied whether or not they exist
+	missing := send(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"nope","arguments":{}}}`)
+	denied := send(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"deploy","arguments":{}}}`)
+	if errorCode(missing) != codeForbidden || errorCode(denied) != codeForbidden {
+		t.Errorf("Expected ungranted names to be denied alike, got %v and %v", missing, denied)
+	}
+
+	if resp := send(`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"get-missing","arguments":{}}}`); errorCode(resp) != codeInvalidParams {
+		t.Errorf("Expected a granted name without a tool to be unknown, got %v", resp)
+	}
+
+	// The workflow is allowed, but its step is not
+	if resp := send(`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"ship","arguments":{}}}`); errorCode(resp) != codeForbidden {
+		t.Errorf("Expected a workflow with a denied step to be denied, got %v", resp)
+	}
+}
@@ -0,0 +1,122 @@
+package mcp
+
+import (
+	"context"
+	"devtool/config"
+	"devtool/tools"
+	"errors"
+	"fmt"
+)
+
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type PromptMessage struct {
+	Role    string        `json:"role"`
+	Content PromptContent `json:"content"`
+}
+
+type PromptContent struct {
+	Type     string            `json:"type"` // "text" or "resource"
+	Text     string            `json:"text,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments"`
+}
+
+// errInvalidPromptArgs is wrapped by getPrompt for missing arguments.
+var errInvalidPromptArgs = fmt.Errorf("invalid prompt arguments")
+
+func listPrompts(prompts []config.PromptConfig) []Prompt {
+	list := []Prompt{}
+	for _, p := range prompts {
+		prompt := Prompt{Name: p.Name, Description: p.Description}
+		for _, a := range p.Arguments {
+			prompt.Arguments = append(prompt.Arguments, PromptArgument{Name: a.Name, Description: a.Description, Required: a.Required})
+		}
+		list = append(list, prompt)
+	}
+	return list
+}
+
+// getPrompt renders a prompt's messages, embedding resources and tool
+// output. Embedded tools are subject to the caller's policies and are
+// audited like tools/call; tools that need approval can't be embedded.
+func (s *Server) getPrompt(ctx context.Context, sess *session, p config.PromptConfig, rawArgs map[string]string) ([]PromptMessage, error) {
+	args := make(map[string]interface{}, len(rawArgs))
+	for k, v := range rawArgs {
+		args[k] = v
+	}
+	for _, a := range p.Arguments {
+		if a.Required && rawArgs[a.Name] == "" {
+			return nil, fmt.Errorf("%w: missing required argument '%s'", errInvalidPromptArgs, a.Name)
+		}
+	}
+
+	cfg := s.snapshot()
+
+	messages := make([]PromptMessage, 0, len(p.Messages))
+	for _, m := range p.Messages {
+		msg := PromptMessage{Role: m.Role}
+		if msg.Role == "" {
+			msg.Role = "user"
+		}
+
+		switch {
+		case m.Resource != "":
+			uri := tools.ExpandParams(m.Resource, args, p.Arguments)
+			contents, err := readResource(ctx, cfg, sess.identity, uri)
+			if err != nil {
+				return nil, fmt.Errorf("failed to embed resource %s: %w", uri, err)
+			}
+			msg.Content = PromptContent{Type: "resource", Resource: &contents[0]}
+		case m.Tool != "":
+			toolArgs := make(map[string]interface{}, len(m.Args))
+			for k, v := range m.Args {
+				if str, ok := v.(string); ok {
+					v = tools.ExpandParams(str, args, p.Arguments)
+				}
+				toolArgs[k] = v
+			}
+			output, err := s.runEmbeddedTool(ctx, sess, cfg, m.Tool, toolArgs)
+			if err != nil {
+				return nil, fmt.Errorf("failed to embed output of %s: %w", m.Tool, err)
+			}
+			msg.Content = PromptContent{Type: "text", Text: output}
+		default:
+			msg.Content = PromptContent{Type: "text", Text: tools.ExpandParams(m.Text, args, p.Arguments)}
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// runEmbeddedTool runs a tool or workflow whose output is embedded in a
+// prompt. It is authorized like a tools/call, but nobody can be asked for
+// approval.
+func (s *Server) runEmbeddedTool(ctx context.Context, sess *session, cfg *config.Config, name string, args map[string]interface{}) (string, error) {
+	_, res, err := s.authorizeAndRun(ctx, nil, sess.log.With("tool", name), sess, cfg, name, args, false)
+	switch {
+	case errors.Is(err, errUnknownTool):
+		return "", fmt.Errorf("tool or workflow not found")
+	case errors.Is(err, errAccessDenied):
+		return "", fmt.Errorf("access denied by policy")
+	case errors.Is(err, errRequiresApproval):
+		return "", fmt.Errorf("it requires approval and can't be used in a prompt")
+	case errors.Is(err, errClientGone):
+		return "", err
+	}
+	return res.Output, err
+}
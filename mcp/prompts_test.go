@@ -0,0 +1,118 @@
+package mcp
+
+import (
+	"devtool/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrompts(t *testing.T) {
+	notes := filepath.Join(t.TempDir(), "notes.md")
+	os.WriteFile(notes, []byte("be nice"), 0644)
+
+	s := NewServer(&config.Config{
+		Tools: []config.ToolConfig{
+			{Name: "diff", Type: "shell", Command: "echo diff of $BRANCH", Parameters: []config.Parameter{{Name: "branch", Type: "string"}}},
+			{Name: "deploy", Type: "shell", Command: "echo deployed", Confirm: true},
+		},
+		Resources: []config.ResourceConfig{{Name: "notes", File: notes}},
+		Prompts: []config.PromptConfig{
+			{
+				Name:      "review",
+				Arguments: []config.Parameter{{Name: "branch", Description: "Branch to review", Required: true}},
+				Messages: []config.PromptMessage{
+					{Text: "Review {{branch}}."},
+					{Tool: "diff", Args: map[string]interface{}{"branch": "{{branch}}"}},
+					{Role: "assistant", Resource: fileURI(notes)},
+				},
+			},
+			{Name: "ship", Messages: []config.PromptMessage{{Tool: "deploy"}}},
+		},
+	}, "")
+	sess, msgs := testSession(t)
+
+	list := call(t, s, sess, msgs, "prompts/list", `{}`)
+	prompts := list["result"].(map[string]interface{})["prompts"].([]interface{})
+	if len(prompts) != 2 || prompts[0].(map[string]interface{})["arguments"] == nil {
+		t.Fatalf("Unexpected prompts: %v", prompts)
+	}
+
+	get := call(t, s, sess, msgs, "prompts/get", `{"name":"review","arguments":{"branch":"main"}}`)
+	if get["error"] != nil {
+		t.Fatalf("prompts/get failed: %v", get["error"])
+	}
+	messages := get["result"].(map[string]interface{})["messages"].([]interface{})
+	content := func(i int) map[string]interface{} {
+		return messages[i].(map[string]interface{})["content"].(map[string]interface{})
+	}
+	if content(0)["text"] != "Review main." {
+		t.Errorf("Unexpected text message: %v", content(0))
+	}
+	if content(1)["text"] != "diff of main\n" {
+		t.Errorf("Expected tool output, got %v", content(1))
+	}
+	if res := content(2)["resource"].(map[string]interface{}); res["text"] != "be nice" || messages[2].(map[string]interface{})["role"] != "assistant" {
+		t.Errorf("Expected embedded resource, got %v", messages[2])
+	}
+
+	for _, tc := range []struct{ params, reason string }{
+		{`{"name":"review","arguments":{}}`, "missing argument"},
+		{`{"name":"unknown"}`, "unknown prompt"},
+	} {
+		if resp := call(t, s, sess, msgs, "prompts/get", tc.params); resp["error"].(map[string]interface{})["code"].(float64) != -32602 {
+			t.Errorf("Expected invalid params for %s, got %v", tc.reason, resp)
+		}
+	}
+
+	if resp := call(t, s, sess, msgs, "prompts/get", `{"name":"ship"}`); resp["error"] == nil {
+		t.Errorf("Expected tools that need approval to be refused, got %v", resp)
+	}
+}
+
+func TestPrompts_EmbeddedOutsideToolsets(t *testing.T) {
+	s := NewServer(&config.Config{
+		Server: config.ServerConfig{Toolsets: []string{"ci"}},
+		Tools: []config.ToolConfig{
+			{Name: "build", Type: "shell", Command: "echo built", Tags: []string{"ci"}},
+			{Name: "migrate", Type: "shell", Command: "echo migrated", Tags: []string{"db"}},
+		},
+		Prompts: []config.PromptConfig{
+			{Name: "status", Messages: []config.PromptMessage{{Tool: "build"}}},
+			{Name: "schema", Messages: []config.PromptMessage{{Tool: "migrate"}}},
+		},
+	}, "")
+	sess, msgs := testSession(t)
+
+	if resp := call(t, s, sess, msgs, "prompts/get", `{"name":"status"}`); resp["error"] != nil {
+		t.Errorf("Expected a tool in the session's toolsets to be embedded, got %v", resp)
+	}
+	if resp := call(t, s, sess, msgs, "prompts/get", `{"name":"schema"}`); resp["error"] == nil {
+		t.Errorf("Expected a tool outside the session's toolsets to be refused, got %v", resp)
+	}
+}
+
+func TestPrompts_ListFollowsPolicies(t *testing.T) {
+	s := NewServer(&config.Config{
+		Tools: []config.ToolConfig{
+			{Name: "status", Type: "shell", Command: "echo ok"},
+			{Name: "migrate", Type: "shell", Command: "echo migrated"},
+		},
+		Workflows: []config.WorkflowConfig{
+			{Name: "upgrade", Steps: []config.StepConfig{{Name: "m", Tool: "migrate"}}},
+		},
+		Prompts: []config.PromptConfig{
+			{Name: "health", Messages: []config.PromptMessage{{Text: "Check"}, {Tool: "status"}}},
+			{Name: "schema", Messages: []config.PromptMessage{{Tool: "migrate"}}},
+			{Name: "release", Messages: []config.PromptMessage{{Tool: "upgrade"}}},
+		},
+		Policies: []config.PolicyConfig{{Identities: []string{"local"}, Allow: []string{"status", "upgrade"}}},
+	}, "")
+	sess, msgs := testSession(t)
+
+	list := call(t, s, sess, msgs, "prompts/list", `{}`)
+	prompts := list["result"].(map[string]interface{})["prompts"].([]interface{})
+	if len(prompts) != 1 || prompts[0].(map[string]interface{})["name"] != "health" {
+		t.Errorf("Expected only the prompt embedding allowed tools, got %v", prompts)
+	}
+}
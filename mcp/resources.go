@@ -0,0 +1,388 @@
+package mcp
+
+import (
+	"context"
+	"devtool/config"
+	"devtool/logger"
+	"devtool/tools"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Error code for reads of unknown resources
+const codeResourceNotFound = -32002
+
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"` // Base64, for binary content
+}
+
+// errResourceNotFound is returned by readResource for unknown URIs.
+var errResourceNotFound = fmt.Errorf("resource not found")
+
+// fileURI returns the file:// URI of a local path.
+func fileURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+func resourceURI(r config.ResourceConfig) string {
+	switch {
+	case r.URI != "":
+		return r.URI
+	case r.File != "":
+		return fileURI(r.File)
+	default:
+		return "devtool://resources/" + r.Name
+	}
+}
+
+func resourceMimeType(r config.ResourceConfig, path string) string {
+	if r.MimeType != "" {
+		return r.MimeType
+	}
+	if path != "" {
+		if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+			return t
+		}
+	}
+	return "text/plain"
+}
+
+// listResources returns the concrete resources: files, every current match
+// of each glob, and commands without a URI template.
+func listResources(resources []config.ResourceConfig) []Resource {
+	list := []Resource{}
+	for _, r := range resources {
+		switch {
+		case r.URITemplate != "":
+			continue
+		case r.Glob != "":
+			matches, _ := filepath.Glob(r.Glob)
+			for _, m := range matches {
+				if info, err := os.Stat(m); err != nil || info.IsDir() {
+					continue
+				}
+				list = append(list, Resource{URI: fileURI(m), Name: m, Description: r.Description, MimeType: resourceMimeType(r, m)})
+			}
+		default:
+			list = append(list, Resource{URI: resourceURI(r), Name: r.Name, Description: r.Description, MimeType: resourceMimeType(r, r.File)})
+		}
+	}
+	return list
+}
+
+func listResourceTemplates(resources []config.ResourceConfig) []ResourceTemplate {
+	list := []ResourceTemplate{}
+	for _, r := range resources {
+		if r.URITemplate != "" {
+			list = append(list, ResourceTemplate{URITemplate: r.URITemplate, Name: r.Name, Description: r.Description, MimeType: resourceMimeType(r, "")})
+		}
+	}
+	return list
+}
+
+var templateVar = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// matchTemplate matches uri against a URI template with simple {var}
+// expressions, each matching one path segment. Values that decode to more
+// than one segment, such as "a%2Fb", don't match.
+func matchTemplate(tmpl, uri string) (map[string]interface{}, bool) {
+	var pattern strings.Builder
+	var names []string
+	last := 0
+	for _, loc := range templateVar.FindAllStringSubmatchIndex(tmpl, -1) {
+		pattern.WriteString(regexp.QuoteMeta(tmpl[last:loc[0]]))
+		pattern.WriteString(`([^/?#]+)`)
+		names = append(names, tmpl[loc[2]:loc[3]])
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(tmpl[last:]))
+
+	re, err := regexp.Compile("^" + pattern.String() + "$")
+	if err != nil {
+		return nil, false
+	}
+	m := re.FindStringSubmatch(uri)
+	if m == nil {
+		return nil, false
+	}
+
+	vars := make(map[string]interface{}, len(names))
+	for i, name := range names {
+		val, err := url.PathUnescape(m[i+1])
+		if err != nil || strings.Contains(val, "/") {
+			return nil, false
+		}
+		vars[name] = val
+	}
+	return vars, true
+}
+
+// readResource returns the contents of a declared resource that the
+// policies grant id access to. Files are only readable if they are declared
+// or match a declared glob.
+func readResource(ctx context.Context, cfg *config.Config, id Identity, uri string) ([]ResourceContents, error) {
+	for _, r := range allowedResources(cfg.Policies, id, cfg.Resources) {
+		switch {
+		case r.URITemplate != "":
+			if vars, ok := matchTemplate(r.URITemplate, uri); ok {
+				return readCommand(ctx, cfg, r, uri, vars)
+			}
+		case r.Glob != "":
+			matches, _ := filepath.Glob(r.Glob)
+			for _, m := range matches {
+				if fileURI(m) == uri {
+					return readFile(cfg, r, uri, m)
+				}
+			}
+		case resourceURI(r) == uri:
+			if r.File != "" {
+				return readFile(cfg, r, uri, r.File)
+			}
+			return readCommand(ctx, cfg, r, uri, nil)
+		}
+	}
+	return nil, errResourceNotFound
+}
+
+// resourceDenied reports whether uri names a resource the policies don't
+// grant the session access to.
+func (s *Server) resourceDenied(sess *session, uri string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, r := range s.Config.Resources {
+		if r.Command == "" {
+			continue
+		}
+		matches := resourceURI(r) == uri
+		if r.URITemplate != "" {
+			_, matches = matchTemplate(r.URITemplate, uri)
+		}
+		if matches && !isAllowed(s.Config.Policies, sess.identity, r.Name, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+func readFile(cfg *config.Config, r config.ResourceConfig, uri, path string) ([]ResourceContents, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	limit := cfg.MaxOutputBytes
+	if limit > 0 {
+		reader = io.LimitReader(f, int64(limit)+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := ResourceContents{URI: uri, MimeType: resourceMimeType(r, path)}
+	if !utf8.Valid(data) {
+		if limit > 0 && len(data) > limit {
+			return nil, fmt.Errorf("binary resource exceeds %d bytes", limit)
+		}
+		contents.Blob = base64.StdEncoding.EncodeToString(data)
+		return []ResourceContents{contents}, nil
+	}
+
+	contents.Text = string(data)
+	if limit > 0 && len(data) > limit {
+		contents.Text = fmt.Sprintf("%s\n... [output truncated after %d bytes] ...\n", data[:limit], limit)
+	}
+	return []ResourceContents{contents}, nil
+}
+
+// readCommand runs a command resource as a shell tool, so template
+// variables are exported as environment variables just like tool arguments.
+// The command is killed after the resource's timeout.
+func readCommand(ctx context.Context, cfg *config.Config, r config.ResourceConfig, uri string, vars map[string]interface{}) ([]ResourceContents, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = config.DefaultResourceTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	tool := config.ToolConfig{
+		Name:           r.Name,
+		Type:           "shell",
+		Command:        r.Command,
+		WorkDir:        r.WorkDir,
+		MaxOutputBytes: cfg.MaxOutputBytes,
+	}
+	for name := range vars {
+		tool.Parameters = append(tool.Parameters, config.Parameter{Name: name, Type: "string"})
+	}
+
+	res, err := tools.RunTool(ctx, tool, vars)
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("command timed out after %s", timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(res.Stderr))
+	}
+	return []ResourceContents{{URI: uri, MimeType: resourceMimeType(r, ""), Text: res.Output}}, nil
+}
+
+// watchResources notifies subscribed sessions when declared files change,
+// and all sessions when files matching a glob come or go.
+func (s *Server) watchResources() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Error("Failed to create resource watcher: %v", err)
+		return
+	}
+	s.watchMu.Lock()
+	s.resourceWatcher = watcher
+	s.watchMu.Unlock()
+	s.updateResourceWatches()
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				s.notifyResourceUpdated(fileURI(event.Name))
+				if event.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 && s.matchesGlob(event.Name) {
+					s.broadcast("notifications/resources/list_changed")
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Error("Resource watcher error: %v", err)
+			}
+		}
+	}()
+}
+
+// updateResourceWatches watches the directories of all file and glob
+// resources, and stops watching the ones no resource needs anymore.
+// Watching directories rather than files survives editors that replace
+// files on save.
+func (s *Server) updateResourceWatches() {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	if s.resourceWatcher == nil {
+		return
+	}
+
+	s.mu.RLock()
+	resources := s.Config.Resources
+	s.mu.RUnlock()
+
+	dirs := make(map[string]bool)
+	for _, r := range resources {
+		var dir string
+		switch {
+		case r.File != "":
+			dir = filepath.Dir(r.File)
+		case r.Glob != "":
+			dir = filepath.Dir(r.Glob)
+		default:
+			continue
+		}
+		if strings.ContainsAny(dir, "*?[") {
+			// Wildcards in directories: watch the existing matches
+			matches, _ := filepath.Glob(dir)
+			for _, m := range matches {
+				if !s.watchedDirs[m] {
+					s.resourceWatcher.Add(m)
+				}
+				dirs[m] = true
+			}
+			continue
+		}
+		if s.watchedDirs[dir] || dirs[dir] {
+			dirs[dir] = true
+			continue
+		}
+		if err := s.resourceWatcher.Add(dir); err != nil {
+			logger.Error("Failed to watch %s for resource %s: %v", dir, r.Name, err)
+			continue
+		}
+		dirs[dir] = true
+	}
+
+	for dir := range s.watchedDirs {
+		if !dirs[dir] {
+			s.resourceWatcher.Remove(dir)
+		}
+	}
+	s.watchedDirs = dirs
+}
+
+func (s *Server) matchesGlob(path string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, r := range s.Config.Resources {
+		if r.Glob == "" {
+			continue
+		}
+		if ok, _ := filepath.Match(r.Glob, path); ok {
+			return true
+		}
+		if abs, err := filepath.Abs(r.Glob); err == nil {
+			if ok, _ := filepath.Match(abs, path); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Server) notifyResourceUpdated(uri string) {
+	for _, sess := range s.activeSessions() {
+		if sess.subscribed(uri) {
+			sess.notify("notifications/resources/updated", map[string]string{"uri": uri})
+		}
+	}
+}
+
+// broadcast sends a notification without params to every session.
+func (s *Server) broadcast(method string) {
+	for _, sess := range s.activeSessions() {
+		// Clients don't expect notifications before the handshake
+		if sess.isInitialized() {
+			sess.notify(method, nil)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package mcp
+
+import (
+	"devtool/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResources(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes"), 0644)
+	os.WriteFile(filepath.Join(dir, "a.log"), []byte("line a"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.log"), []byte{0xff, 0xfe, 0x00}, 0644)
+	os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("nope"), 0644)
+
+	s := NewServer(&config.Config{Resources: []config.ResourceConfig{
+		{Name: "notes", File: filepath.Join(dir, "notes.md")},
+		{Name: "logs", Glob: filepath.Join(dir, "*.log")},
+		{Name: "status", Command: "echo clean"},
+		{Name: "show", URITemplate: "git://commits/{sha}", Command: "echo commit $SHA"},
+	}}, "")
+	sess, msgs := testSession(t)
+
+	list := call(t, s, sess, msgs, "resources/list", `{}`)
+	resources := list["result"].(map[string]interface{})["resources"].([]interface{})
+	if len(resources) != 4 {
+		t.Fatalf("Expected notes, two logs and status, got %v", resources)
+	}
+
+	templates := call(t, s, sess, msgs, "resources/templates/list", `{}`)
+	tmpl := templates["result"].(map[string]interface{})["resourceTemplates"].([]interface{})
+	if len(tmpl) != 1 || tmpl[0].(map[string]interface{})["uriTemplate"] != "git://commits/{sha}" {
+		t.Errorf("Unexpected templates: %v", tmpl)
+	}
+
+	read := func(uri string) map[string]interface{} {
+		resp := call(t, s, sess, msgs, "resources/read", `{"uri":"`+uri+`"}`)
+		if resp["error"] != nil {
+			return resp
+		}
+		return resp["result"].(map[string]interface{})["contents"].([]interface{})[0].(map[string]interface{})
+	}
+
+	if c := read(fileURI(filepath.Join(dir, "notes.md"))); c["text"] != "# Notes" || c["mimeType"] == "" {
+		t.Errorf("Unexpected notes contents: %v", c)
+	}
+	if c := read(fileURI(filepath.Join(dir, "b.log"))); c["blob"] != "//4A" {
+		t.Errorf("Expected binary content as a blob, got %v", c)
+	}
+	if c := read("devtool://resources/status"); strings.TrimSpace(c["text"].(string)) != "clean" {
+		t.Errorf("Unexpected command output: %v", c)
+	}
+	if c := read("git://commits/abc123"); strings.TrimSpace(c["text"].(string)) != "commit abc123" {
+		t.Errorf("Unexpected templated output: %v", c)
+	}
+
+	// Undeclared files can't be read
+	resp := read(fileURI(filepath.Join(dir, "secret.txt")))
+	if e, ok := resp["error"].(map[string]interface{}); !ok || e["code"].(float64) != codeResourceNotFound {
+		t.Errorf("Expected resource not found, got %v", resp)
+	}
+}
+
+func TestMatchTemplate(t *testing.T) {
+	if vars, ok := matchTemplate("git://commits/{sha}", "git://commits/abc%20123"); !ok || vars["sha"] != "abc 123" {
+		t.Errorf("Expected an escaped value to match, got %v", vars)
+	}
+	for _, uri := range []string{"git://commits/a/b", "git://commits/..%2Fetc", "git://commits/"} {
+		if _, ok := matchTemplate("git://commits/{sha}", uri); ok {
+			t.Errorf("Expected %s not to match", uri)
+		}
+	}
+}
+
+func TestResources_Policies(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes"), 0644)
+
+	s := NewServer(&config.Config{
+		Resources: []config.ResourceConfig{
+			{Name: "notes", File: filepath.Join(dir, "notes.md")},
+			{Name: "status", Command: "echo clean"},
+			{Name: "show", URITemplate: "git://commits/{sha}", Command: "echo commit $SHA"},
+		},
+		Policies: []config.PolicyConfig{{Identities: []string{"local"}, Allow: []string{"status"}}},
+	}, "")
+	sess, msgs := testSession(t)
+
+	list := call(t, s, sess, msgs, "resources/list", `{}`)
+	if resources := list["result"].(map[string]interface{})["resources"].([]interface{}); len(resources) != 2 {
+		t.Errorf("Expected notes and status, got %v", resources)
+	}
+	templates := call(t, s, sess, msgs, "resources/templates/list", `{}`)
+	if tmpl := templates["result"].(map[string]interface{})["resourceTemplates"].([]interface{}); len(tmpl) != 0 {
+		t.Errorf("Expected the denied template to be hidden, got %v", tmpl)
+	}
+
+	if resp := call(t, s, sess, msgs, "resources/read", `{"uri":"devtool://resources/status"}`); resp["error"] != nil {
+		t.Errorf("Expected the allowed command to be readable, got %v", resp)
+	}
+	for _, method := range []string{"resources/read", "resources/subscribe"} {
+		resp := call(t, s, sess, msgs, method, `{"uri":"git://commits/abc"}`)
+		if e, ok := resp["error"].(map[string]interface{}); !ok || e["code"].(float64) != codeResourceNotFound {
+			t.Errorf("%s: expected resource not found, got %v", method, resp)
+		}
+	}
+}
+
+func TestResources_CommandTimeout(t *testing.T) {
+	s := NewServer(&config.Config{Resources: []config.ResourceConfig{
+		{Name: "slow", Command: "sleep 10", Timeout: 100 * time.Millisecond},
+	}}, "")
+	sess, msgs := testSession(t)
+
+	start := time.Now()
+	resp := call(t, s, sess, msgs, "resources/read", `{"uri":"devtool://resources/slow"}`)
+	e, ok := resp["error"].(map[string]interface{})
+	if !ok || !strings.Contains(e["message"].(string), "timed out") {
+		t.Errorf("Expected a timeout error, got %v", resp)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the command to be killed, took %s", elapsed)
+	}
+}
+
+func TestResourceSubscription(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.md")
+	os.WriteFile(path, []byte("v1"), 0644)
+
+	s := NewServer(&config.Config{Resources: []config.ResourceConfig{{Name: "notes", File: path}}}, "")
+	s.watchResources()
+	defer s.resourceWatcher.Close()
+
+	sess, msgs := testSession(t)
+	s.sessions[sess] = true
+
+	uri := fileURI(path)
+	if resp := call(t, s, sess, msgs, "resources/subscribe", `{"uri":"`+uri+`"}`); resp["error"] != nil {
+		t.Fatalf("Subscribe failed: %v", resp)
+	}
+
+	os.WriteFile(path, []byte("v2"), 0644)
+
+	select {
+	case msg := <-msgs:
+		params, _ := msg["params"].(map[string]interface{})
+		if msg["method"] != "notifications/resources/updated" || params["uri"] != uri {
+			t.Errorf("Unexpected notification: %v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an update notification")
+	}
+}
+
+func TestUpdateResourceWatches(t *testing.T) {
+	kept, dropped := t.TempDir(), t.TempDir()
+	s := NewServer(&config.Config{Resources: []config.ResourceConfig{
+		{Name: "notes", File: filepath.Join(kept, "notes.md")},
+		{Name: "logs", Glob: filepath.Join(dropped, "*.log")},
+	}}, "")
+	s.watchResources()
+	defer s.resourceWatcher.Close()
+
+	s.mu.Lock()
+	s.Config = &config.Config{Resources: []config.ResourceConfig{{Name: "notes", File: filepath.Join(kept, "notes.md")}}}
+	s.mu.Unlock()
+	s.updateResourceWatches()
+
+	if watched := s.resourceWatcher.WatchList(); len(watched) != 1 || watched[0] != kept {
+		t.Errorf("Expected only %s to stay watched, got %v", kept, watched)
+	}
+}
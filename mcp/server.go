@@ -2,16 +2,22 @@ package mcp
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"devtool/config"
 	"devtool/logger"
 	"devtool/tools"
+	"devtool/tracing"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/fsnotify/fsnotify"
 	"io"
 	"net"
 	"os"
+	"strings"
 	"sync"
+	"time"
 )
 
 // JSON-RPC types
@@ -30,49 +36,208 @@ type JSONRPCResponse struct {
 }
 
 type JSONRPCError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"` // Details, e.g. why params are invalid
 }
 
 // MCP types
 type Tool struct {
-	Name        string      `json:"name"`
-	Description string      `json:"description"`
-	InputSchema InputSchema `json:"inputSchema"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	InputSchema InputSchema      `json:"inputSchema"`
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations are behaviour hints for clients, available since
+// 2025-03-26.
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    bool   `json:"readOnlyHint"`
+	DestructiveHint bool   `json:"destructiveHint"`
+	IdempotentHint  bool   `json:"idempotentHint"`
+	OpenWorldHint   bool   `json:"openWorldHint"`
 }
 
 type InputSchema struct {
 	Type       string                 `json:"type"`
 	Properties map[string]interface{} `json:"properties"`
 	Required   []string               `json:"required,omitempty"`
+
+	// Only set for tools imported from MCP servers
+	Defs                 map[string]interface{} `json:"$defs,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
 }
 
 type CallToolParams struct {
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments"`
+	Meta      struct {
+		Traceparent string `json:"traceparent,omitempty"` // W3C trace context of the caller
+	} `json:"_meta"`
 }
 
 type CallToolResult struct {
-	Content []Content `json:"content"`
-	IsError bool      `json:"isError,omitempty"`
-}
-
-type Content struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Content           []Content   `json:"content"`
+	StructuredContent interface{} `json:"structuredContent,omitempty"`
+	IsError           bool        `json:"isError,omitempty"`
 }
 
 type Server struct {
 	Config     *config.Config
 	ConfigFile string
 	mu         sync.RWMutex
+
+	// Config.Tools with the tools of MCP servers imported, and the config
+	// they were imported for
+	tools       []config.ToolConfig
+	toolsConfig *config.Config
+	importMu    sync.Mutex  // Serializes importTools
+	importRetry *time.Timer // Pending retry of a failed import
+	unwatch     func()      // Unregisters the upstream change listener
+	closed      bool
+
+	sessionsMu sync.Mutex
+	sessions   map[*session]bool
+
+	watchMu         sync.Mutex // Guards the resource watches
+	resourceWatcher *fsnotify.Watcher
+	watchedDirs     map[string]bool
 }
 
+const (
+	// importTimeout bounds importing the tools of all MCP servers.
+	importTimeout = time.Minute
+
+	// importRetryDelay is how long to wait before importing again from
+	// servers that couldn't be reached.
+	importRetryDelay = 30 * time.Second
+)
+
 func NewServer(cfg *config.Config, configFile string) *Server {
-	return &Server{
+	s := &Server{
 		Config:     cfg,
 		ConfigFile: configFile,
+		sessions:   make(map[*session]bool),
+	}
+	s.unwatch = tools.OnUpstreamToolsChanged(func() {
+		s.mu.RLock()
+		cfg := s.Config
+		s.mu.RUnlock()
+		if s.importTools(cfg) {
+			s.broadcast("notifications/tools/list_changed")
+		}
+	})
+	// Importing may wait on MCP servers, so it doesn't delay startup;
+	// clients are told once the imported tools are available.
+	go func() {
+		if s.importTools(cfg) {
+			s.broadcast("notifications/tools/list_changed")
+		}
+	}()
+	return s
+}
+
+// Close stops importing the tools of MCP servers for s: it no longer
+// listens for changes and drops a pending retry.
+func (s *Server) Close() {
+	s.unwatch()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.importRetry != nil {
+		s.importRetry.Stop()
+		s.importRetry = nil
+	}
+}
+
+// importTools imports the tools of the MCP servers in cfg and caches them
+// for snapshot, unless another config has been loaded meanwhile. It runs
+// when a config is loaded and when a server reports that its tools changed,
+// so that requests never wait on MCP servers. Servers that can't be reached
+// are logged, left out and tried again after importRetryDelay. It reports
+// whether the names of the tools clients see changed.
+func (s *Server) importTools(cfg *config.Config) bool {
+	s.importMu.Lock()
+	defer s.importMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+	defer cancel()
+	imported, err := tools.ImportTools(ctx, cfg.Tools)
+	if err != nil {
+		logger.Warn("Failed to import MCP tools: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Config != cfg {
+		return false
+	}
+	changed := !sameNames(s.listedTools(), imported)
+	s.tools, s.toolsConfig = imported, cfg
+
+	if err != nil && s.importRetry == nil && !s.closed {
+		s.importRetry = time.AfterFunc(importRetryDelay, func() {
+			s.mu.Lock()
+			s.importRetry = nil
+			cfg := s.Config
+			s.mu.Unlock()
+			if s.importTools(cfg) {
+				s.broadcast("notifications/tools/list_changed")
+			}
+		})
+	}
+	return changed
+}
+
+func sameNames(a, b []config.ToolConfig) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshot returns the current config with the tools of MCP servers
+// imported. Until they have been imported for a new config, MCP servers
+// are left out.
+func (s *Server) snapshot() *config.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cfg := *s.Config
+	cfg.Tools = s.listedTools()
+	return &cfg
+}
+
+// listedTools returns the tools of the current config as clients see them.
+// The caller must hold s.mu.
+func (s *Server) listedTools() []config.ToolConfig {
+	if s.toolsConfig == s.Config {
+		return s.tools
+	}
+	var list []config.ToolConfig
+	for _, t := range s.Config.Tools {
+		if t.Type != "mcp" {
+			list = append(list, t)
+		}
+	}
+	return list
+}
+
+// activeSessions returns the currently connected sessions.
+func (s *Server) activeSessions() []*session {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	list := make([]*session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		list = append(list, sess)
 	}
+	return list
 }
 
 func (s *Server) WatchConfig() {
@@ -99,12 +264,23 @@ func (s *Server) WatchConfig() {
 					newCfg, err := config.LoadConfig(s.ConfigFile)
 					if err != nil {
 						logger.Error("Failed to reload config: %v", err)
+						configReloadsTotal.Inc("failure")
 						continue
 					}
-					
+
 					s.mu.Lock()
 					s.Config = newCfg
 					s.mu.Unlock()
+					tools.PruneUpstreams(newCfg.Tools)
+					s.importTools(newCfg)
+					if err := logger.Configure(newCfg.Logging.Level, newCfg.Logging.Format); err != nil {
+						logger.Error("Invalid logging settings: %v", err)
+					}
+					s.updateResourceWatches()
+					s.broadcast("notifications/tools/list_changed")
+					s.broadcast("notifications/resources/list_changed")
+					s.broadcast("notifications/prompts/list_changed")
+					configReloadsTotal.Inc("success")
 					logger.Info("Configuration reloaded successfully.")
 				}
 			case err, ok := <-watcher.Errors:
@@ -124,22 +300,51 @@ func (s *Server) WatchConfig() {
 	}
 }
 
-func (s *Server) ServeStdio() {
+// ServeStdio serves a single session over stdin and stdout until the
+// client closes stdin or ctx is done.
+func (s *Server) ServeStdio(ctx context.Context) error {
+	// Resources are watched before reloads can update the watches
+	s.watchResources()
 	s.WatchConfig()
 	ip := getLocalIP()
 	logger.Info("MCP Server started. Status: Running. Mode: Stdio. IP: %s", ip)
-	s.serveStream(os.Stdin, os.Stdout)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.serveStream(os.Stdin, os.Stdout, Identity{Name: "local", Method: "none", Transport: "stdio"})
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	return nil
 }
 
-func (s *Server) ServeTCP(port int) {
-	addr := fmt.Sprintf(":%d", port)
-	listener, err := net.Listen("tcp", addr)
+// ServeTCP accepts connections on port until ctx is done. It only returns
+// an error if it can't listen.
+func (s *Server) ServeTCP(ctx context.Context, port int) error {
+	s.mu.RLock()
+	serverCfg := s.Config.Server
+	s.mu.RUnlock()
+
+	listener, err := listen(serverCfg, port)
 	if err != nil {
-		logger.Error("Failed to start TCP server: %v", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to start TCP server: %w", err)
 	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	// Resources are watched before reloads can update the watches
+	s.watchResources()
 	s.WatchConfig()
 
+	if !serverCfg.Auth.Enabled() && serverCfg.TLS == nil {
+		logger.Warn("TCP server has no authentication configured. Anyone who can reach %s can run tools.", listener.Addr())
+	}
+
 	ip := getLocalIP()
 	// Get actual port if 0 was passed
 	port = listener.Addr().(*net.TCPAddr).Port
@@ -148,65 +353,217 @@ func (s *Server) ServeTCP(port int) {
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
 			logger.Error("Accept error: %v", err)
 			continue
 		}
-		go s.serveStream(conn, conn)
+		go s.serveConn(conn)
 	}
 }
 
-func (s *Server) serveStream(r io.Reader, w io.Writer) {
+// serveConn authenticates a TCP connection and then serves it.
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	reader, id, err := s.authenticate(conn)
+	if err != nil {
+		logger.Error("Rejected connection from %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	logger.Info("Accepted connection from %s as %s (%s)", id.Remote, id.Name, id.Method)
+
+	s.serveStream(reader, conn, id)
+}
+
+func (s *Server) serveStream(r io.Reader, w io.Writer, id Identity) {
+	sess := newSession(w, id)
+	stopLogs := sess.forwardLogs()
+	activeSessions.Inc()
+	s.sessionsMu.Lock()
+	s.sessions[sess] = true
+	s.sessionsMu.Unlock()
+	defer func() {
+		activeSessions.Dec()
+		s.sessionsMu.Lock()
+		delete(s.sessions, sess)
+		s.sessionsMu.Unlock()
+		stopLogs()
+		// Cancel handlers and fail requests still waiting on the client,
+		// then let handlers finish
+		sess.close()
+		sess.inflight.Wait()
+	}()
+
 	scanner := bufio.NewScanner(r)
 	// Increase buffer size just in case
 	buf := make([]byte, 1024*1024)
 	scanner.Buffer(buf, 1024*1024)
 
 	for scanner.Scan() {
-		line := scanner.Bytes()
+		line := bytes.TrimSpace(scanner.Bytes())
 		if len(line) == 0 {
 			continue
 		}
 
-		var req JSONRPCRequest
-		if err := json.Unmarshal(line, &req); err != nil {
-			// Log error to stderr, don't crash
-			logger.Error("Failed to parse JSON: %v", err)
+		var raw json.RawMessage
+		if err := json.Unmarshal(line, &raw); err != nil {
+			sess.log.Error("Failed to parse JSON: %v", err)
+			s.reply(sess, errorResponse(nil, codeParseError, "Parse error", err.Error()))
+			continue
+		}
+
+		if raw[0] == '[' {
+			var items []json.RawMessage
+			json.Unmarshal(raw, &items)
+			if len(items) == 0 {
+				s.reply(sess, errorResponse(nil, codeInvalidRequest, "Invalid Request", "batch must not be empty"))
+				continue
+			}
+			if err := checkBatch(sess.version()); err != nil {
+				s.reply(sess, errorResponse(nil, codeInvalidRequest, "Invalid Request", err.Error()))
+				continue
+			}
+			sess.inflight.Add(1)
+			go func() {
+				defer sess.inflight.Done()
+				s.handleBatch(sess, items)
+			}()
 			continue
 		}
 
-		s.handleRequest(req, w)
+		msg, errResp := parseMessage(raw)
+		if errResp != nil {
+			s.reply(sess, errResp)
+			continue
+		}
+
+		// Responses to requests the server sent to the client
+		if msg.Method == "" {
+			if !sess.deliver(msg) {
+				sess.log.Error("Received response for unknown request %v", msg.ID)
+			}
+			continue
+		}
+
+		req := msg.request()
+
+		// Tool calls may wait on the client (e.g. for approval), and
+		// prompts, resources and completions may run commands, so they are
+		// handled in the background while the connection keeps being read.
+		switch req.Method {
+		case "tools/call", "prompts/get", "resources/read", "completion/complete":
+			sess.inflight.Add(1)
+			go func() {
+				defer sess.inflight.Done()
+				s.handleRequest(sess, req)
+			}()
+		default:
+			s.handleRequest(sess, req)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		sess.log.Error("Failed to read from client: %v", err)
+	}
+}
+
+// reply sends a response, if there is one.
+func (s *Server) reply(sess *session, resp *JSONRPCResponse) {
+	if resp == nil {
+		return
 	}
+	if err := sess.send(resp); err != nil {
+		sess.log.Error("Failed to send response: %v", err)
+	}
+}
+
+// handleRequest answers a single request or notification.
+func (s *Server) handleRequest(sess *session, req JSONRPCRequest) {
+	s.reply(sess, s.dispatch(sess, req))
 }
 
-func (s *Server) handleRequest(req JSONRPCRequest, w io.Writer) {
+// dispatch handles a request and returns its response, or nil for
+// notifications.
+func (s *Server) dispatch(sess *session, req JSONRPCRequest) *JSONRPCResponse {
 	var resp JSONRPCResponse
 	resp.JSONRPC = "2.0"
 	resp.ID = req.ID
+	log := sess.log.With("request_id", req.ID, "method", req.Method)
+	requestsTotal.Inc(methodLabel(req.Method))
+
+	// Notifications get no response, so requests sent without an ID are
+	// ignored rather than run unseen
+	if req.ID == nil && !strings.HasPrefix(req.Method, "notifications/") {
+		log.Warn("Ignoring %s sent as a notification", req.Method)
+		return nil
+	}
+
+	// Only pings may precede initialize
+	if req.Method != "initialize" && req.Method != "ping" && !sess.isInitialized() {
+		if req.ID == nil {
+			return nil
+		}
+		return errorResponse(req.ID, codeInvalidRequest, "Invalid Request", "session not initialized")
+	}
 
 	switch req.Method {
 	case "initialize":
-		resp.Result = map[string]interface{}{
-			"protocolVersion": "2024-11-05", // Example version
-			"capabilities": map[string]interface{}{
-				"tools": map[string]interface{}{},
-			},
-			"serverInfo": map[string]string{
-				"name":    "devtool",
-				"version": "0.1.0",
-			},
+		result, err := s.initialize(sess, req.Params)
+		switch {
+		case errors.Is(err, errInvalidParams):
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: fmt.Sprintf("Invalid params: %v", err)}
+		case err != nil:
+			resp.Error = &JSONRPCError{Code: codeInvalidRequest, Message: fmt.Sprintf("Invalid request: %v", err)}
+		default:
+			resp.Result = result
 		}
+	case "ping":
+		resp.Result = map[string]interface{}{}
 	case "notifications/initialized":
 		// No response needed for notifications
-		return
+		return nil
+	case "logging/setLevel":
+		var params struct {
+			Level string `json:"level"`
+		}
+		json.Unmarshal(req.Params, &params)
+		level, err := parseMCPLevel(params.Level)
+		if err != nil {
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: fmt.Sprintf("Invalid params: %v", err)}
+			break
+		}
+		sess.setLogLevel(level)
+		resp.Result = map[string]interface{}{}
 	case "tools/list":
+		var params ListToolsParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: "Invalid params", Data: err.Error()}
+				break
+			}
+		}
 		toolList := []Tool{}
-		
-		s.mu.RLock()
-		tools := s.Config.Tools
-		workflows := s.Config.Workflows
-		s.mu.RUnlock()
+
+		cfg := s.snapshot()
+		tools := cfg.Tools
+		workflows := cfg.Workflows
+		policies := cfg.Policies
+		pageSize := cfg.Server.PageSize
+		selected := s.toolsets(sess)
+
+		// Hidden tools: denied by policy, outside the session's toolsets,
+		// or without any of the requested tags
+		hidden := func(name string, tags []string) bool {
+			return !isAllowed(policies, sess.identity, name, tags) || !inToolsets(selected, tags) ||
+				(len(params.Tags) > 0 && !hasAnyTag(tags, params.Tags))
+		}
 
 		for _, t := range tools {
+			if hidden(t.Name, t.Tags) {
+				continue
+			}
+
 			props := make(map[string]interface{})
 			required := []string{}
 			for _, p := range t.Parameters {
@@ -219,21 +576,29 @@ func (s *Server) handleRequest(req JSONRPCRequest, w io.Writer) {
 				}
 			}
 
+			schema := InputSchema{
+				Type:       "object",
+				Properties: props,
+				Required:   required,
+			}
+			if t.InputSchema != nil {
+				schema = importedSchema(t.InputSchema)
+			}
+
 			toolList = append(toolList, Tool{
 				Name:        t.Name,
 				Description: t.Description,
-				InputSchema: InputSchema{
-					Type:       "object",
-					Properties: props,
-					Required:   required,
-				},
+				InputSchema: schema,
+				Annotations: toolAnnotations(t.Hints(), sess.version()),
 			})
 		}
-		
 
-		
 		// Add Workflows
 		for _, w := range workflows {
+			if hidden(w.Name, w.Tags) || !workflowAllowed(policies, sess.identity, w, tools) {
+				continue
+			}
+
 			props := make(map[string]interface{})
 			required := []string{}
 			for _, p := range w.Parameters {
@@ -254,98 +619,313 @@ func (s *Server) handleRequest(req JSONRPCRequest, w io.Writer) {
 					Properties: props,
 					Required:   required,
 				},
+				Annotations: toolAnnotations(w.Hints(tools), sess.version()),
 			})
 		}
 
-		resp.Result = map[string]interface{}{
-			"tools": toolList,
+		page, next, err := paginateTools(toolList, params.Cursor, pageSize)
+		if err != nil {
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: "Invalid params", Data: err.Error()}
+			break
 		}
-	case "tools/call":
-		var params CallToolParams
+		result := map[string]interface{}{
+			"tools": page,
+		}
+		if next != "" {
+			result["nextCursor"] = next
+		}
+		resp.Result = result
+	case "devtool/toolsets/list":
+		resp.Result = map[string]interface{}{"toolsets": s.listToolsets(sess)}
+	case "devtool/toolsets/select":
+		var params SelectToolsetsParams
 		if err := json.Unmarshal(req.Params, &params); err != nil {
-			resp.Error = &JSONRPCError{Code: -32700, Message: "Parse error"}
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: "Invalid params", Data: err.Error()}
+			break
+		}
+		if err := s.selectToolsets(sess, params.Toolsets); err != nil {
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: fmt.Sprintf("Invalid params: %v", err)}
+			break
+		}
+		log.Info("Selected toolsets %v", params.Toolsets)
+		sess.notify("notifications/tools/list_changed", nil)
+		resp.Result = map[string]interface{}{"toolsets": s.listToolsets(sess)}
+	case "resources/list":
+		s.mu.RLock()
+		resources := allowedResources(s.Config.Policies, sess.identity, s.Config.Resources)
+		s.mu.RUnlock()
+		resp.Result = map[string]interface{}{"resources": listResources(resources)}
+	case "resources/templates/list":
+		s.mu.RLock()
+		resources := allowedResources(s.Config.Policies, sess.identity, s.Config.Resources)
+		s.mu.RUnlock()
+		resp.Result = map[string]interface{}{"resourceTemplates": listResourceTemplates(resources)}
+	case "resources/read":
+		var params struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.URI == "" {
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: "Invalid params: uri is required"}
 			break
 		}
-
-		// Find tool or workflow
-		var selectedTool *config.ToolConfig
-		var selectedWorkflow *config.WorkflowConfig
 
 		s.mu.RLock()
-		cfgTools := s.Config.Tools
-		cfgWorkflows := s.Config.Workflows
+		cfg := s.Config
 		s.mu.RUnlock()
 
-		for _, t := range cfgTools {
-			if t.Name == params.Name {
-				selectedTool = &t
-				break
+		contents, err := readResource(sess.ctx, cfg, sess.identity, params.URI)
+		if errors.Is(err, errResourceNotFound) {
+			resp.Error = &JSONRPCError{Code: codeResourceNotFound, Message: fmt.Sprintf("Resource not found: %s", params.URI)}
+			break
+		}
+		if err != nil {
+			log.Error("Failed to read resource %s: %v", params.URI, err)
+			resp.Error = &JSONRPCError{Code: codeInternalError, Message: fmt.Sprintf("Failed to read resource: %v", err)}
+			break
+		}
+		resp.Result = map[string]interface{}{"contents": contents}
+	case "resources/subscribe", "resources/unsubscribe":
+		var params struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.URI == "" {
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: "Invalid params: uri is required"}
+			break
+		}
+		if req.Method == "resources/subscribe" && s.resourceDenied(sess, params.URI) {
+			resp.Error = &JSONRPCError{Code: codeResourceNotFound, Message: fmt.Sprintf("Resource not found: %s", params.URI)}
+			break
+		}
+		sess.subscribe(params.URI, req.Method == "resources/subscribe")
+		resp.Result = map[string]interface{}{}
+	case "prompts/list":
+		cfg := s.snapshot()
+		var prompts []config.PromptConfig
+		for _, p := range cfg.Prompts {
+			if promptAllowed(cfg.Policies, sess.identity, p, cfg) {
+				prompts = append(prompts, p)
 			}
 		}
+		resp.Result = map[string]interface{}{"prompts": listPrompts(prompts)}
+	case "prompts/get":
+		var params GetPromptParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: "Invalid params"}
+			break
+		}
 
-		if selectedTool == nil {
-			for _, w := range cfgWorkflows {
-				if w.Name == params.Name {
-					selectedWorkflow = &w
-					break
-				}
+		s.mu.RLock()
+		prompts := s.Config.Prompts
+		s.mu.RUnlock()
+
+		var prompt *config.PromptConfig
+		for i := range prompts {
+			if prompts[i].Name == params.Name {
+				prompt = &prompts[i]
+				break
 			}
 		}
+		if prompt == nil {
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: fmt.Sprintf("Invalid params: prompt '%s' not found", params.Name)}
+			break
+		}
 
-		if selectedTool == nil && selectedWorkflow == nil {
-			resp.Error = &JSONRPCError{Code: -32601, Message: "Tool or Workflow not found"}
+		messages, err := s.getPrompt(sess.ctx, sess, *prompt, params.Arguments)
+		if errors.Is(err, errInvalidPromptArgs) {
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: fmt.Sprintf("Invalid params: %v", err)}
+			break
+		}
+		if err != nil {
+			log.Error("Failed to render prompt %s: %v", params.Name, err)
+			resp.Error = &JSONRPCError{Code: codeInternalError, Message: fmt.Sprintf("Failed to render prompt: %v", err)}
+			break
+		}
+		resp.Result = map[string]interface{}{
+			"description": prompt.Description,
+			"messages":    messages,
+		}
+	case "completion/complete":
+		// Completions only exist since 2025-03-26
+		if sess.version() < "2025-03-26" {
+			resp.Error = &JSONRPCError{Code: codeMethodNotFound, Message: "Method not found", Data: map[string]string{"method": req.Method}}
+			break
+		}
+		var params CompleteParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: "Invalid params"}
+			break
+		}
+		completion, err := s.complete(sess.ctx, sess, params)
+		if errors.Is(err, errInvalidRef) {
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: fmt.Sprintf("Invalid params: %v", err)}
+			break
+		}
+		if err != nil {
+			// Completion is best effort: report no suggestions
+			log.Error("Completion of %s failed: %v", params.Argument.Name, err)
+		}
+		resp.Result = map[string]interface{}{"completion": completion}
+	case "tools/call":
+		var params CallToolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: "Invalid params", Data: err.Error()}
+			break
+		}
+		if params.Name == "" {
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: "Invalid params", Data: "name is required"}
 			break
 		}
 
-		// Execute
-		logger.Info("Executing %s with params: %v", params.Name, params.Arguments)
-		
-		var output string
-		var err error
+		log = log.With("tool", params.Name)
 
-		if selectedTool != nil {
-			output, err = tools.ExecuteTool(*selectedTool, params.Arguments)
-		} else {
-			// Note: We are passing cfgTools to ExecuteWorkflow, if ExecuteWorkflow does not modify the slice/map
-			// it should be fine. However, since we are under RLock above, we copied the slice headers.
-			// Ideally ExecuteWorkflow should be safe.
-			output, err = tools.ExecuteWorkflow(*selectedWorkflow, cfgTools, params.Arguments)
+		ctx := tracing.WithRemoteParent(sess.ctx, params.Meta.Traceparent)
+		ctx, span := tracing.Start(ctx, "tools/call "+params.Name, tracing.KindServer)
+		span.SetAttribute("mcp.method", "tools/call")
+		span.SetAttribute("mcp.session", sess.id)
+		span.SetAttribute("devtool.identity", sess.identity.Name)
+		defer span.Finish()
+
+		cfg := s.snapshot()
+		selectedTool, res, err := s.authorizeAndRun(ctx, span, log, sess, cfg, params.Name, params.Arguments, true)
+
+		var notApproved *notApprovedError
+		if errors.Is(err, errUnknownTool) {
+			resp.Error = &JSONRPCError{Code: codeInvalidParams, Message: fmt.Sprintf("Unknown tool: %s", params.Name)}
+			break
 		}
+		if errors.Is(err, errAccessDenied) {
+			resp.Error = &JSONRPCError{
+				Code:    codeForbidden,
+				Message: fmt.Sprintf("Access denied: '%s' is not allowed to call '%s'", sess.identity.Name, params.Name),
+			}
+			break
+		}
+		if errors.As(err, &notApproved) {
+			resp.Result = CallToolResult{
+				Content: []Content{
+					{Type: "text", Text: fmt.Sprintf("Execution of %s was not approved: %s", params.Name, notApproved.reason)},
+				},
+				IsError: true,
+			}
+			break
+		}
+		if errors.Is(err, errClientGone) {
+			resp.Error = &JSONRPCError{Code: codeInternalError, Message: "Request cancelled"}
+			break
+		}
+		output := res.Output
 
 		isError := false
 		if err != nil {
 			isError = true
 			output = fmt.Sprintf("Error: %v\nOutput: %s", err, output)
-			logger.Error("Execution %s finished with error: %v. Output: %s", params.Name, err, output)
+			log.Error("Execution %s finished with error: %v. Output: %s", params.Name, err, output)
 		} else {
-			logger.Info("Execution %s finished successfully.", params.Name)
+			log.Info("Execution %s finished successfully.", params.Name)
 		}
 
-		resp.Result = CallToolResult{
+		result := CallToolResult{
 			Content: []Content{
 				{Type: "text", Text: output},
 			},
 			IsError: isError,
 		}
 
+		// Successful tool output is returned as its declared or detected type
+		var structured interface{}
+		if err == nil && selectedTool != nil {
+			content, data, cerr := toolContent(*selectedTool, res, sess.version())
+			if cerr != nil {
+				log.Error("Failed to convert output of %s: %v", params.Name, cerr)
+				content, result.IsError = Content{Type: "text", Text: fmt.Sprintf("Error: %v", cerr)}, true
+			}
+			result.Content[0], structured = content, data
+		}
+
+		if res != nil && res.Stderr != "" {
+			log.With("stream", "stderr").Info("%s", res.Stderr)
+		}
+
+		// Shell tools report stderr as its own block and the raw streams
+		// plus exit code as structured content. Binary stdout is only
+		// returned as content.
+		if selectedTool != nil && selectedTool.Type == "shell" {
+			if res.Stderr != "" {
+				result.Content = append(result.Content, Content{Type: "text", Text: "stderr:\n" + res.Stderr})
+			}
+			streams := map[string]interface{}{
+				"stderr":   res.Stderr,
+				"exitCode": res.ExitCode,
+			}
+			if result.Content[0].Type == "text" {
+				streams["stdout"] = res.Output
+			}
+			result.StructuredContent = streams
+		}
+		if structured != nil {
+			result.StructuredContent = structured
+		}
+
+		// Quota errors tell the client when to try again
+		var rateErr *tools.RateLimitError
+		if errors.As(err, &rateErr) {
+			result.StructuredContent = map[string]interface{}{
+				"retryAfterSeconds": int(rateErr.RetryAfter.Seconds()),
+			}
+		}
+
+		// Structured content only exists since 2025-06-18
+		if sess.version() < "2025-06-18" {
+			result.StructuredContent = nil
+		}
+		resp.Result = result
+
 	default:
 		// Ignore unknown notifications, return error for unknown requests with ID
-		if req.ID != nil {
-			resp.Error = &JSONRPCError{Code: -32601, Message: "Method not found"}
-		} else {
-			return
+		if req.ID == nil {
+			return nil
 		}
+		resp.Error = &JSONRPCError{Code: codeMethodNotFound, Message: "Method not found", Data: map[string]string{"method": req.Method}}
 	}
 
-	// Send response
-	bytes, err := json.Marshal(resp)
-	if err != nil {
-		logger.Error("Failed to marshal response: %v", err)
-		return
+	if req.ID == nil {
+		return nil
+	}
+	return &resp
+}
+
+// toolAnnotations converts hints for a session, or returns nil if its
+// protocol version predates annotations.
+func toolAnnotations(h config.Hints, version string) *ToolAnnotations {
+	if version < "2025-03-26" {
+		return nil
+	}
+	return &ToolAnnotations{
+		Title:           h.Title,
+		ReadOnlyHint:    h.ReadOnly,
+		DestructiveHint: h.Destructive,
+		IdempotentHint:  h.Idempotent,
+		OpenWorldHint:   h.OpenWorld,
+	}
+}
+
+// importedSchema converts the input schema of a tool imported from an MCP
+// server.
+func importedSchema(schema map[string]interface{}) InputSchema {
+	in := InputSchema{Type: "object", Properties: map[string]interface{}{}}
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		in.Properties = props
+	}
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if s, ok := name.(string); ok {
+				in.Required = append(in.Required, s)
+			}
+		}
 	}
-	// Write to the specific writer
-	w.Write(bytes)
-	w.Write([]byte("\n"))
+	in.Defs, _ = schema["$defs"].(map[string]interface{})
+	in.AdditionalProperties = schema["additionalProperties"]
+	return in
 }
 
 func getLocalIP() string {
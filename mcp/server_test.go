@@ -0,0 +1,197 @@
+package mcp
+
+import (
+	"context"
+	"devtool/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestImportedTools(t *testing.T) {
+	// An upstream MCP server with one tool
+	var lists int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		var result interface{}
+		switch req.Method {
+		case "initialize":
+			result = map[string]interface{}{"protocolVersion": "2025-06-18", "serverInfo": map[string]string{"name": "tickets"}}
+		case "tools/list":
+			atomic.AddInt32(&lists, 1)
+			result = map[string]interface{}{"tools": []interface{}{map[string]interface{}{
+				"name": "create_ticket",
+				"inputSchema": map[string]interface{}{
+					"type":                 "object",
+					"properties":           map[string]interface{}{"ticket": map[string]interface{}{"$ref": "#/$defs/Ticket"}},
+					"required":             []string{"ticket"},
+					"$defs":                map[string]interface{}{"Ticket": map[string]interface{}{"type": "object"}},
+					"additionalProperties": false,
+				},
+			}}}
+		case "tools/call":
+			result = map[string]interface{}{
+				"content":           []interface{}{map[string]string{"type": "text", "text": `{"id":7}`}},
+				"structuredContent": map[string]interface{}{"id": 7},
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	defer upstream.Close()
+
+	s := NewServer(&config.Config{
+		Tools: []config.ToolConfig{
+			{Name: "tickets", Type: "mcp", URL: upstream.URL, Tags: []string{"tickets"}, MCP: &config.MCPConfig{Prefix: "jira_"}},
+		},
+		Workflows: []config.WorkflowConfig{
+			{Name: "file", Steps: []config.StepConfig{{Name: "create", Tool: "jira_create_ticket"}}, Output: "{{create}}"},
+		},
+		Policies: []config.PolicyConfig{{Identities: []string{"local"}, Tags: []string{"tickets"}, Allow: []string{"file"}}},
+	}, "")
+	defer s.Close()
+	waitImported(t, s)
+
+	send := testStream(t, s)
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`)
+	resp := send(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	raw, _ := json.Marshal(resp["result"])
+	var list struct {
+		Tools []Tool `json:"tools"`
+	}
+	json.Unmarshal(raw, &list)
+	if len(list.Tools) != 2 || list.Tools[0].Name != "jira_create_ticket" {
+		t.Fatalf("Expected the imported tool and the workflow, got %s", raw)
+	}
+	schema := list.Tools[0].InputSchema
+	if !reflect.DeepEqual(schema.Required, []string{"ticket"}) || schema.Defs["Ticket"] == nil || schema.AdditionalProperties != false {
+		t.Errorf("Expected the upstream schema, got %+v", schema)
+	}
+
+	resp = send(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"jira_create_ticket","arguments":{"ticket":{}}}}`)
+	result, _ := resp["result"].(map[string]interface{})
+	if result["isError"] == true || !reflect.DeepEqual(result["structuredContent"], map[string]interface{}{"id": float64(7)}) {
+		t.Errorf("Expected the upstream's structured content, got %v", resp)
+	}
+
+	resp = send(`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"file","arguments":{}}}`)
+	result, _ = resp["result"].(map[string]interface{})
+	content, _ := result["content"].([]interface{})
+	if result["isError"] == true || len(content) == 0 || content[0].(map[string]interface{})["text"] != `{"id":7}` {
+		t.Errorf("Expected the workflow to call the imported tool, got %v", resp)
+	}
+
+	// The tools were imported once, when the server was created
+	if n := atomic.LoadInt32(&lists); n != 1 {
+		t.Errorf("Expected the upstream tools to be listed once, got %d", n)
+	}
+}
+
+func TestToolAnnotations(t *testing.T) {
+	s := NewServer(&config.Config{
+		Tools: []config.ToolConfig{
+			{Name: "list", URL: "http://localhost/items", Annotations: &config.Annotations{Title: "List items"}},
+			{Name: "build", Type: "shell", Command: "make"},
+		},
+		Workflows: []config.WorkflowConfig{
+			{Name: "ci", Steps: []config.StepConfig{{Tool: "list"}, {Tool: "build"}}},
+		},
+	}, "")
+
+	send := testStream(t, s)
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`)
+	resp := send(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	raw, _ := json.Marshal(resp["result"])
+	var result struct {
+		Tools []Tool `json:"tools"`
+	}
+	json.Unmarshal(raw, &result)
+
+	expected := map[string]ToolAnnotations{
+		"list":  {Title: "List items", ReadOnlyHint: true, IdempotentHint: true, OpenWorldHint: true},
+		"build": {DestructiveHint: true, OpenWorldHint: true},
+		"ci":    {DestructiveHint: true, OpenWorldHint: true},
+	}
+	if len(result.Tools) != len(expected) {
+		t.Fatalf("Expected %d tools, got %s", len(expected), raw)
+	}
+	for _, tool := range result.Tools {
+		if tool.Annotations == nil || *tool.Annotations != expected[tool.Name] {
+			t.Errorf("%s: expected %+v, got %+v", tool.Name, expected[tool.Name], tool.Annotations)
+		}
+	}
+
+	// Annotations didn't exist in 2024-11-05
+	send = testStream(t, s)
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`)
+	resp = send(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	raw, _ = json.Marshal(resp["result"])
+	if strings.Contains(string(raw), "annotations") {
+		t.Errorf("Expected no annotations for 2024-11-05, got %s", raw)
+	}
+}
+
+func TestServeTCP_Shutdown(t *testing.T) {
+	s := NewServer(&config.Config{Server: config.ServerConfig{Bind: "127.0.0.1"}}, "")
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.ServeTCP(ctx, 0) }()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected ServeTCP to return once the context is done")
+	}
+
+	bad := NewServer(&config.Config{Server: config.ServerConfig{Bind: "256.0.0.1"}}, "")
+	defer bad.Close()
+	if err := bad.ServeTCP(context.Background(), 0); err == nil {
+		t.Error("Expected an error for an address that can't be listened on")
+	}
+}
+
+func TestNewServer_DoesNotWaitOnUpstreams(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	start := time.Now()
+	s := NewServer(&config.Config{
+		Tools: []config.ToolConfig{
+			{Name: "slow", Type: "mcp", URL: upstream.URL},
+			{Name: "hello", Type: "shell", Command: "echo hi"},
+		},
+	}, "")
+	defer s.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected NewServer not to wait on MCP servers, took %s", elapsed)
+	}
+
+	send := testStream(t, s)
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`)
+	resp := send(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	if tools := resp["result"].(map[string]interface{})["tools"].([]interface{}); len(tools) != 1 {
+		t.Errorf("Expected only the local tool until the import finishes, got %v", tools)
+	}
+}
@@ -0,0 +1,232 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"devtool/logger"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// message is any incoming JSON-RPC message: a request, a notification, or a
+// response to a request the server sent to the client.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      interface{}     `json:"id,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+}
+
+// session is the state of one client connection.
+type session struct {
+	id       string
+	identity Identity
+	w        io.Writer
+	log      *logger.Logger
+
+	writeMu sync.Mutex
+
+	mu                 sync.Mutex
+	nextID             int
+	pending            map[string]chan *message
+	closed             bool
+	initialized        bool   // initialize has been answered
+	protocolVersion    string // Negotiated in initialize
+	clientInfo         Implementation
+	clientCapabilities map[string]interface{}
+	minLevel           slog.Level      // Minimum level forwarded to the client
+	subscriptions      map[string]bool // Resource URIs the client subscribed to
+	toolsets           []string        // Selected toolsets, if toolsetsSelected
+	toolsetsSelected   bool
+
+	// Requests still being handled in the background, and the context they
+	// run in, which is cancelled when the client disconnects
+	inflight sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func newSession(w io.Writer, identity Identity) *session {
+	id := make([]byte, 8)
+	rand.Read(id)
+	sid := hex.EncodeToString(id)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &session{
+		id:            sid,
+		identity:      identity,
+		w:             w,
+		log:           logger.With("session", sid, "identity", identity.Name),
+		pending:       make(map[string]chan *message),
+		subscriptions: make(map[string]bool),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// send writes one message followed by a newline.
+func (c *session) send(msg interface{}) error {
+	bytes, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.w.Write(append(bytes, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+// request sends a request to the client and waits for its response.
+func (c *session) request(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("session closed")
+	}
+	c.nextID++
+	id := fmt.Sprintf("devtool-%d", c.nextID)
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	req := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	}
+	if err := c.send(req); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, fmt.Errorf("session closed")
+		}
+		if resp.Error != nil {
+			return nil, fmt.Errorf("%s failed: %s (code %d)", method, resp.Error.Message, resp.Error.Code)
+		}
+		return resp.Result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// deliver hands a client response to the waiting request, if any.
+func (c *session) deliver(resp *message) bool {
+	key := fmt.Sprintf("%v", resp.ID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch, ok := c.pending[key]
+	if !ok {
+		return false
+	}
+	delete(c.pending, key)
+	ch <- resp // buffered, never blocks
+	return true
+}
+
+// close cancels the requests being handled and fails all outstanding
+// client requests.
+func (c *session) close() {
+	c.cancel()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+// initialize records the outcome of the initialize handshake. It returns
+// false if the session was already initialized.
+func (c *session) initialize(version string, caps map[string]interface{}, info Implementation) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.initialized {
+		return false
+	}
+	c.initialized = true
+	c.protocolVersion = version
+	c.clientCapabilities = caps
+	c.clientInfo = info
+	return true
+}
+
+func (c *session) isInitialized() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.initialized
+}
+
+// version returns the negotiated protocol version, or "" before initialize.
+func (c *session) version() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.protocolVersion
+}
+
+// clientSupports reports whether the client declared a capability.
+func (c *session) clientSupports(capability string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.clientCapabilities[capability]
+	return ok
+}
+
+// notify sends a notification to the client. params may be nil.
+func (c *session) notify(method string, params interface{}) error {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	return c.send(msg)
+}
+
+func (c *session) subscribe(uri string, on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if on {
+		c.subscriptions[uri] = true
+	} else {
+		delete(c.subscriptions, uri)
+	}
+}
+
+func (c *session) subscribed(uri string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subscriptions[uri]
+}
+
+// selectToolsets sets the toolsets the session sees; nil restores the
+// server default.
+func (c *session) selectToolsets(names []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.toolsets = names
+	c.toolsetsSelected = names != nil
+}
+
+func (c *session) selectedToolsets() ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.toolsets, c.toolsetsSelected
+}
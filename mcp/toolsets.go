@@ -0,0 +1,163 @@
+package mcp
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ListToolsParams are the params of tools/list. Tags is an extension that
+// narrows the list to tools and workflows with any of the tags.
+type ListToolsParams struct {
+	Cursor string   `json:"cursor,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// Toolset is a group of tools sharing a tag, which sessions can select to
+// see only the tools they need.
+type Toolset struct {
+	Name     string `json:"name"`
+	Tools    int    `json:"tools"` // Tools and workflows the session may call
+	Selected bool   `json:"selected"`
+}
+
+type SelectToolsetsParams struct {
+	Toolsets []string `json:"toolsets"` // null restores the server default
+}
+
+func hasAnyTag(tags, wanted []string) bool {
+	for _, tag := range tags {
+		for _, w := range wanted {
+			if tag == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inToolsets reports whether something tagged with tags belongs to one of
+// the selected toolsets. An empty selection includes everything.
+func inToolsets(selected, tags []string) bool {
+	return len(selected) == 0 || hasAnyTag(tags, selected)
+}
+
+// toolsets returns the toolsets selected by the session, or the configured
+// default if it selected none.
+func (s *Server) toolsets(sess *session) []string {
+	if selected, ok := sess.selectedToolsets(); ok {
+		return selected
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Config.Server.Toolsets
+}
+
+// listToolsets returns every tag of a tool or workflow the session may call.
+func (s *Server) listToolsets(sess *session) []Toolset {
+	cfg := s.snapshot()
+
+	counts := make(map[string]int)
+	count := func(tags []string) {
+		for _, tag := range tags {
+			counts[tag]++
+		}
+	}
+	for _, t := range cfg.Tools {
+		if isAllowed(cfg.Policies, sess.identity, t.Name, t.Tags) {
+			count(t.Tags)
+		}
+	}
+	for _, w := range cfg.Workflows {
+		if workflowAllowed(cfg.Policies, sess.identity, w, cfg.Tools) {
+			count(w.Tags)
+		}
+	}
+
+	selected := s.toolsets(sess)
+	list := make([]Toolset, 0, len(counts))
+	for name, n := range counts {
+		list = append(list, Toolset{Name: name, Tools: n, Selected: hasAnyTag([]string{name}, selected)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// selectToolsets changes the session's selection. Unknown toolsets are
+// rejected so that typos don't silently hide every tool.
+func (s *Server) selectToolsets(sess *session, names []string) error {
+	if names != nil {
+		known := make(map[string]bool)
+		for _, ts := range s.listToolsets(sess) {
+			known[ts.Name] = true
+		}
+		var unknown []string
+		for _, name := range names {
+			if !known[name] {
+				unknown = append(unknown, name)
+			}
+		}
+		if len(unknown) > 0 {
+			return fmt.Errorf("%w: unknown toolsets %s", errInvalidParams, strings.Join(unknown, ", "))
+		}
+	}
+	sess.selectToolsets(names)
+	return nil
+}
+
+// Cursors are opaque to clients; they encode the offset of the next page
+// and a fingerprint of the list it belongs to, so that a cursor issued
+// before the list changed can't silently skip or repeat tools.
+func encodeCursor(offset int, fingerprint string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset) + ":" + fingerprint))
+}
+
+func decodeCursor(cursor string) (int, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if rest, ok := strings.CutPrefix(string(data), "offset:"); ok {
+			n, fingerprint, _ := strings.Cut(rest, ":")
+			if offset, err := strconv.Atoi(n); err == nil && offset >= 0 {
+				return offset, fingerprint, nil
+			}
+		}
+	}
+	return 0, "", fmt.Errorf("%w: invalid cursor", errInvalidParams)
+}
+
+// listFingerprint identifies a tool list by the names in it.
+func listFingerprint(list []Tool) string {
+	h := sha256.New()
+	for _, t := range list {
+		h.Write([]byte(t.Name))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// paginateTools returns the page starting at cursor and the cursor of the
+// next page, if any. A size of zero or less disables pagination. Cursors of
+// a list that has changed since are rejected.
+func paginateTools(list []Tool, cursor string, size int) ([]Tool, string, error) {
+	fingerprint := listFingerprint(list)
+	offset := 0
+	if cursor != "" {
+		var issued string
+		var err error
+		if offset, issued, err = decodeCursor(cursor); err != nil {
+			return nil, "", err
+		}
+		if issued != fingerprint || offset > len(list) {
+			return nil, "", fmt.Errorf("%w: the tool list changed since the cursor was issued", errInvalidParams)
+		}
+	}
+	list = list[offset:]
+	if size <= 0 || len(list) <= size {
+		return list, "", nil
+	}
+	return list[:size], encodeCursor(offset+size, fingerprint), nil
+}
@@ -0,0 +1,155 @@
+package mcp
+
+import (
+	"devtool/audit"
+	"devtool/config"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func toolsetsServer(defaults []string) *Server {
+	cfg := &config.Config{
+		Server: config.ServerConfig{PageSize: 2, Toolsets: defaults},
+		Tools: []config.ToolConfig{
+			{Name: "build", Type: "shell", Command: "echo build", Tags: []string{"ci"}},
+			{Name: "test", Type: "shell", Command: "echo test", Tags: []string{"ci"}},
+			{Name: "migrate", Type: "shell", Command: "echo migrate", Tags: []string{"db"}},
+			{Name: "backup", Type: "shell", Command: "echo backup", Tags: []string{"db", "ops"}},
+			{Name: "hello", Type: "shell", Command: "echo hello"},
+		},
+		Workflows: []config.WorkflowConfig{
+			{Name: "release", Tags: []string{"ci"}, Steps: []config.StepConfig{{Tool: "build"}}},
+		},
+	}
+	return NewServer(cfg, "")
+}
+
+// listAll follows nextCursor until the last page and returns all names.
+func listAll(t *testing.T, s *Server, sess *session, msgs <-chan map[string]interface{}, extra string) []string {
+	t.Helper()
+	var names []string
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		params := `{` + extra + `}`
+		if cursor != "" {
+			sep := ""
+			if extra != "" {
+				sep = ","
+			}
+			params = fmt.Sprintf(`{"cursor":%q%s%s}`, cursor, sep, extra)
+		}
+		resp := call(t, s, sess, msgs, "tools/list", params)
+		result, ok := resp["result"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("tools/list failed: %v", resp)
+		}
+		tools := result["tools"].([]interface{})
+		if len(tools) > 2 {
+			t.Errorf("Expected at most 2 tools per page, got %d", len(tools))
+		}
+		for _, tool := range tools {
+			names = append(names, tool.(map[string]interface{})["name"].(string))
+		}
+		next, _ := result["nextCursor"].(string)
+		if next == "" {
+			return names
+		}
+		cursor = next
+	}
+	t.Fatal("Too many pages")
+	return nil
+}
+
+func TestToolsListPagination(t *testing.T) {
+	s := toolsetsServer(nil)
+	sess, msgs := testSession(t)
+
+	want := []string{"build", "test", "migrate", "backup", "hello", "release"}
+	if got := listAll(t, s, sess, msgs, ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got := listAll(t, s, sess, msgs, `"tags":["db","ops"]`); !reflect.DeepEqual(got, []string{"migrate", "backup"}) {
+		t.Errorf("Expected the db and ops tools, got %v", got)
+	}
+
+	resp := call(t, s, sess, msgs, "tools/list", `{"cursor":"not-a-cursor"}`)
+	if errorCode(resp) != codeInvalidParams {
+		t.Errorf("Expected -32602 for an invalid cursor, got %v", resp)
+	}
+
+	// A cursor only continues the list it was issued for
+	first := call(t, s, sess, msgs, "tools/list", `{}`)
+	cursor := first["result"].(map[string]interface{})["nextCursor"].(string)
+	resp = call(t, s, sess, msgs, "tools/list", `{"cursor":"`+cursor+`","tags":["ci"]}`)
+	if errorCode(resp) != codeInvalidParams {
+		t.Errorf("Expected -32602 for a cursor of another list, got %v", resp)
+	}
+}
+
+func TestToolsets(t *testing.T) {
+	auditFile := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := audit.Setup(config.AuditConfig{File: auditFile}); err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Setup(config.AuditConfig{})
+
+	s := toolsetsServer([]string{"db"})
+	sess, msgs := testSession(t)
+
+	// The configured default applies until the session selects toolsets
+	if got := listAll(t, s, sess, msgs, ""); !reflect.DeepEqual(got, []string{"migrate", "backup"}) {
+		t.Errorf("Expected the default db toolset, got %v", got)
+	}
+	resp := call(t, s, sess, msgs, "tools/call", `{"name":"build","arguments":{}}`)
+	if errorCode(resp) != codeInvalidParams {
+		t.Errorf("Expected tools outside the toolsets to be unknown, got %v", resp)
+	}
+	if data, _ := os.ReadFile(auditFile); !strings.Contains(string(data), `"status":"denied"`) {
+		t.Errorf("Expected the refused call in the audit log, got %s", data)
+	}
+
+	resp = call(t, s, sess, msgs, "devtool/toolsets/list", `{}`)
+	raw, _ := json.Marshal(resp["result"])
+	var listed struct {
+		Toolsets []Toolset `json:"toolsets"`
+	}
+	json.Unmarshal(raw, &listed)
+	want := []Toolset{{Name: "ci", Tools: 3}, {Name: "db", Tools: 2, Selected: true}, {Name: "ops", Tools: 1}}
+	if !reflect.DeepEqual(listed.Toolsets, want) {
+		t.Errorf("Expected %+v, got %+v", want, listed.Toolsets)
+	}
+
+	if resp := call(t, s, sess, msgs, "devtool/toolsets/select", `{"toolsets":["ci","bogus"]}`); errorCode(resp) != codeInvalidParams {
+		t.Errorf("Expected -32602 for an unknown toolset, got %v", resp)
+	}
+
+	if msg := call(t, s, sess, msgs, "devtool/toolsets/select", `{"toolsets":["ci"]}`); msg["method"] != "notifications/tools/list_changed" {
+		t.Errorf("Expected a list_changed notification, got %v", msg)
+	}
+	if resp := <-msgs; resp["error"] != nil {
+		t.Fatalf("select failed: %v", resp)
+	}
+	if got := listAll(t, s, sess, msgs, ""); !reflect.DeepEqual(got, []string{"build", "test", "release"}) {
+		t.Errorf("Expected the ci toolset, got %v", got)
+	}
+	if resp := call(t, s, sess, msgs, "tools/call", `{"name":"build","arguments":{}}`); resp["error"] != nil {
+		t.Errorf("Expected build to be callable, got %v", resp)
+	}
+
+	// An empty selection shows everything, null restores the default
+	call(t, s, sess, msgs, "devtool/toolsets/select", `{"toolsets":[]}`)
+	<-msgs
+	if got := listAll(t, s, sess, msgs, ""); len(got) != 6 {
+		t.Errorf("Expected all tools, got %v", got)
+	}
+	call(t, s, sess, msgs, "devtool/toolsets/select", `{"toolsets":null}`)
+	<-msgs
+	if got := listAll(t, s, sess, msgs, ""); len(got) != 2 {
+		t.Errorf("Expected the default toolset again, got %v", got)
+	}
+}
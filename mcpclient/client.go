@@ -0,0 +1,337 @@
+// Package mcpclient is a minimal MCP client, used to import the tools of
+// other MCP servers. It speaks newline-delimited JSON-RPC over the stdio of
+// a subprocess or a TCP connection, and the Streamable HTTP transport.
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// ProtocolVersion is the MCP revision requested in initialize.
+const ProtocolVersion = "2025-06-18"
+
+// maxPages bounds the tools/list pages followed, in case a server keeps
+// returning cursors.
+const maxPages = 100
+
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type Tool struct {
+	Name        string                 `json:"name"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	Annotations *ToolAnnotations       `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations are the behaviour hints of a tool; unset hints are nil.
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    *bool  `json:"readOnlyHint,omitempty"`
+	DestructiveHint *bool  `json:"destructiveHint,omitempty"`
+	IdempotentHint  *bool  `json:"idempotentHint,omitempty"`
+	OpenWorldHint   *bool  `json:"openWorldHint,omitempty"`
+}
+
+type CallToolParams struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+	Meta      map[string]interface{} `json:"_meta,omitempty"`
+}
+
+type CallToolResult struct {
+	Content           []Content   `json:"content"`
+	StructuredContent interface{} `json:"structuredContent,omitempty"`
+	IsError           bool        `json:"isError,omitempty"`
+}
+
+// Content is a block of a tool result.
+type Content struct {
+	Type     string            `json:"type"` // "text", "image", "audio", "resource" or "resource_link"
+	Text     string            `json:"text,omitempty"`
+	Data     string            `json:"data,omitempty"` // Base64, for images and audio
+	MimeType string            `json:"mimeType,omitempty"`
+	URI      string            `json:"uri,omitempty"` // Resource links only
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"` // Base64
+}
+
+// Error is an error response from the server.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Data) > 0 {
+		return fmt.Sprintf("%s (%d): %s", e.Message, e.Code, e.Data)
+	}
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// ErrClosed is returned by requests on a client that was closed.
+var ErrClosed = errors.New("client closed")
+
+// Options configure a client.
+type Options struct {
+	// OnNotification is called for every notification from the server,
+	// e.g. notifications/tools/list_changed. It must not block.
+	OnNotification func(method string, params json.RawMessage)
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// transport carries messages to the server. Messages from the server are
+// passed to the client's receive method.
+type transport interface {
+	send(ctx context.Context, data []byte) error
+	close() error
+}
+
+// Client is a connection to an MCP server. It is safe for concurrent use.
+type Client struct {
+	t    transport
+	opts Options
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[string]chan *message
+	err     error // Why the connection ended, once it has
+	done    chan struct{}
+
+	// Set by Initialize
+	ProtocolVersion string
+	ServerInfo      Implementation
+}
+
+func newClient(opts Options) *Client {
+	return &Client{
+		opts:    opts,
+		pending: make(map[string]chan *message),
+		done:    make(chan struct{}),
+	}
+}
+
+// Err returns why the connection ended, or nil while it is usable.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close ends the connection; pending requests fail with ErrClosed.
+func (c *Client) Close() error {
+	c.fail(ErrClosed)
+	return c.t.close()
+}
+
+// fail ends the connection with err, unless it already ended.
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+		close(c.done)
+	}
+}
+
+// receive handles a message or batch of messages from the server.
+func (c *Client) receive(data []byte) {
+	if len(data) > 0 && data[0] == '[' {
+		var batch []json.RawMessage
+		json.Unmarshal(data, &batch)
+		for _, item := range batch {
+			c.receive(item)
+		}
+		return
+	}
+
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+	switch {
+	case msg.Method != "" && msg.ID != nil:
+		go c.answer(&msg)
+	case msg.Method != "":
+		if c.opts.OnNotification != nil {
+			c.opts.OnNotification(msg.Method, msg.Params)
+		}
+	default:
+		c.mu.Lock()
+		ch := c.pending[string(msg.ID)]
+		delete(c.pending, string(msg.ID))
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- &msg
+		}
+	}
+}
+
+// answer responds to a request from the server. Only pings are supported;
+// the client declares no capabilities for anything else.
+func (c *Client) answer(req *message) {
+	resp := message{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &Error{Code: -32601, Message: "Method not found"}
+	}
+	data, _ := json.Marshal(resp)
+	c.t.send(context.Background(), data)
+}
+
+// request sends a request and decodes its result into result, if not nil.
+// When ctx ends first, the server is told to cancel the request.
+func (c *Client) request(ctx context.Context, method string, params, result interface{}) error {
+	ch := make(chan *message, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := strconv.FormatInt(c.nextID, 10)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	req := message{JSONRPC: "2.0", ID: json.RawMessage(id), Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = raw
+	}
+	data, _ := json.Marshal(req)
+	if err := c.t.send(ctx, data); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// The connection is unusable; callers should reconnect
+		c.fail(err)
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result != nil {
+			return json.Unmarshal(resp.Result, result)
+		}
+		return nil
+	case <-c.done:
+		return c.Err()
+	case <-ctx.Done():
+		go c.notify(context.Background(), "notifications/cancelled", map[string]interface{}{
+			"requestId": json.RawMessage(id),
+			"reason":    ctx.Err().Error(),
+		})
+		return ctx.Err()
+	}
+}
+
+// notify sends a notification.
+func (c *Client) notify(ctx context.Context, method string, params interface{}) error {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	data, _ := json.Marshal(msg)
+	return c.t.send(ctx, data)
+}
+
+// Authenticate presents a bearer token to a devtool TCP server, which
+// expects it before initialize.
+func (c *Client) Authenticate(ctx context.Context, token string) error {
+	return c.request(ctx, "authenticate", map[string]string{"token": token}, nil)
+}
+
+// Initialize performs the MCP handshake.
+func (c *Client) Initialize(ctx context.Context, info Implementation) error {
+	var result struct {
+		ProtocolVersion string         `json:"protocolVersion"`
+		ServerInfo      Implementation `json:"serverInfo"`
+	}
+	params := map[string]interface{}{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      info,
+	}
+	if err := c.request(ctx, "initialize", params, &result); err != nil {
+		return fmt.Errorf("initialize failed: %w", err)
+	}
+	c.ProtocolVersion = result.ProtocolVersion
+	c.ServerInfo = result.ServerInfo
+	if v, ok := c.t.(interface{ setVersion(string) }); ok {
+		v.setVersion(result.ProtocolVersion)
+	}
+	return c.notify(ctx, "notifications/initialized", nil)
+}
+
+// ListTools returns all tools of the server, following pages.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for page := 0; page < maxPages; page++ {
+		var params interface{}
+		if cursor != "" {
+			params = map[string]string{"cursor": cursor}
+		}
+		var result struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := c.request(ctx, "tools/list", params, &result); err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+	return tools, fmt.Errorf("tools/list returned more than %d pages", maxPages)
+}
+
+// CallTool calls a tool. Tools that fail report it in the result; errors
+// are only returned when the call itself failed.
+func (c *Client) CallTool(ctx context.Context, params CallToolParams) (*CallToolResult, error) {
+	if params.Arguments == nil {
+		params.Arguments = map[string]interface{}{}
+	}
+	var result CallToolResult
+	if err := c.request(ctx, "tools/call", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,266 @@
+package mcpclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveFake answers a request to a fake MCP server, which lists its tools
+// "echo" and "fail" on two pages.
+func serveFake(msg message) (interface{}, *Error) {
+	var params struct {
+		Cursor    string                 `json:"cursor"`
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+		Token     string                 `json:"token"`
+	}
+	json.Unmarshal(msg.Params, &params)
+
+	switch msg.Method {
+	case "authenticate":
+		if params.Token != "secret" {
+			return nil, &Error{Code: -32001, Message: "Unauthorized"}
+		}
+		return map[string]interface{}{}, nil
+	case "initialize":
+		return map[string]interface{}{
+			"protocolVersion": ProtocolVersion,
+			"serverInfo":      Implementation{Name: "fake", Version: "1.0"},
+		}, nil
+	case "tools/list":
+		if params.Cursor == "" {
+			return map[string]interface{}{"tools": []Tool{{Name: "echo"}}, "nextCursor": "2"}, nil
+		}
+		return map[string]interface{}{"tools": []Tool{{Name: "fail"}}}, nil
+	case "tools/call":
+		if params.Name == "fail" {
+			return CallToolResult{Content: []Content{{Type: "text", Text: "boom"}}, IsError: true}, nil
+		}
+		return CallToolResult{Content: []Content{{Type: "text", Text: fmt.Sprint(params.Arguments["text"])}}}, nil
+	case "slow":
+		time.Sleep(time.Minute)
+	}
+	return nil, &Error{Code: -32601, Message: "Method not found"}
+}
+
+// serveStream runs the fake server over newline-delimited JSON. Before
+// listing tools it announces a change and pings the client.
+func serveStream(r io.Reader, w io.Writer) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var msg message
+		json.Unmarshal(scanner.Bytes(), &msg)
+		if msg.ID == nil {
+			continue
+		}
+		if msg.Method == "" {
+			// The client's answer to our ping
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","method":"notifications/pong","params":%s}`+"\n", msg.Result)
+			continue
+		}
+		if msg.Method == "tools/list" {
+			io.WriteString(w, `{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`+"\n")
+			io.WriteString(w, `{"jsonrpc":"2.0","id":"srv-1","method":"ping"}`+"\n")
+		}
+		go func(msg message) {
+			result, rpcErr := serveFake(msg)
+			resp := message{JSONRPC: "2.0", ID: msg.ID, Error: rpcErr}
+			if result != nil {
+				resp.Result, _ = json.Marshal(result)
+			}
+			data, _ := json.Marshal(resp)
+			w.Write(append(data, '\n'))
+		}(msg)
+	}
+}
+
+// TestHelperServer is not a real test: it runs the fake server on stdio
+// when started by TestStart.
+func TestHelperServer(t *testing.T) {
+	if os.Getenv("MCPCLIENT_HELPER") != "1" {
+		t.Skip("helper process")
+	}
+	serveStream(os.Stdin, os.Stdout)
+	os.Exit(0)
+}
+
+func testClient(t *testing.T, c *Client) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.Initialize(ctx, Implementation{Name: "test", Version: "1"}); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if c.ServerInfo.Name != "fake" || c.ProtocolVersion != ProtocolVersion {
+		t.Errorf("Unexpected server %+v, protocol %s", c.ServerInfo, c.ProtocolVersion)
+	}
+
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	if len(tools) != 2 || tools[0].Name != "echo" || tools[1].Name != "fail" {
+		t.Errorf("Expected both pages of tools, got %+v", tools)
+	}
+
+	result, err := c.CallTool(ctx, CallToolParams{Name: "echo", Arguments: map[string]interface{}{"text": "hi"}})
+	if err != nil || result.IsError || len(result.Content) != 1 || result.Content[0].Text != "hi" {
+		t.Errorf("Unexpected echo result %+v, %v", result, err)
+	}
+	result, err = c.CallTool(ctx, CallToolParams{Name: "fail"})
+	if err != nil || !result.IsError {
+		t.Errorf("Expected a failed tool to be reported in the result, got %+v, %v", result, err)
+	}
+
+	var rpcErr *Error
+	if err := c.request(ctx, "no/such", nil, nil); !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("Expected a -32601 error, got %v", err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperServer$")
+	cmd.Env = append(os.Environ(), "MCPCLIENT_HELPER=1")
+
+	notifications := make(chan string, 8)
+	c, err := Start(cmd, Options{OnNotification: func(method string, params json.RawMessage) {
+		notifications <- method + " " + string(params)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testClient(t, c)
+
+	seen := map[string]bool{}
+	for len(seen) < 2 {
+		select {
+		case n := <-notifications:
+			seen[n] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected a list change and the answer to a ping, got %v", seen)
+		}
+	}
+	if !seen["notifications/tools/list_changed "] || !seen["notifications/pong {}"] {
+		t.Errorf("Unexpected notifications %v", seen)
+	}
+
+	c.Close()
+	if _, err := c.ListTools(context.Background()); err != ErrClosed {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestDial(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				serveStream(conn, conn)
+			}()
+		}
+	}()
+
+	c, err := Dial(context.Background(), listener.Addr().String(), nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Authenticate(context.Background(), "wrong"); err == nil {
+		t.Error("Expected a wrong token to be rejected")
+	}
+	if err := c.Authenticate(context.Background(), "secret"); err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	testClient(t, c)
+
+	// Requests end with their context
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.request(ctx, "slow", nil, nil); err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to end the request, got %v", err)
+	}
+	if c.Err() != nil {
+		t.Errorf("Expected the connection to survive a cancelled request: %v", c.Err())
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	var deleted bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			deleted = r.Header.Get("Mcp-Session-Id") == "abc"
+			return
+		}
+
+		var msg message
+		json.NewDecoder(r.Body).Decode(&msg)
+		switch {
+		case msg.Method == "initialize":
+			w.Header().Set("Mcp-Session-Id", "abc")
+		case r.Header.Get("Mcp-Session-Id") != "abc" || r.Header.Get("MCP-Protocol-Version") != ProtocolVersion:
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+		if msg.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		result, rpcErr := serveFake(msg)
+		resp := message{JSONRPC: "2.0", ID: msg.ID, Error: rpcErr}
+		if result != nil {
+			resp.Result, _ = json.Marshal(result)
+		}
+		data, _ := json.Marshal(resp)
+		if msg.Method == "tools/call" {
+			// Answer as an event stream, preceded by a notification
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	var progress []string
+	c := NewHTTP(srv.URL, http.Header{"Authorization": {"Bearer secret"}}, Options{
+		OnNotification: func(method string, params json.RawMessage) { progress = append(progress, method) },
+	})
+	testClient(t, c)
+	if strings.Join(progress, ",") != "notifications/progress,notifications/progress" {
+		t.Errorf("Expected the notifications in event streams, got %v", progress)
+	}
+
+	c.Close()
+	if !deleted {
+		t.Error("Expected Close to end the session")
+	}
+}
@@ -0,0 +1,154 @@
+package mcpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpTransport implements the Streamable HTTP transport: every message is
+// POSTed to one endpoint, which answers with JSON or a stream of
+// server-sent events. Server-initiated streams (GET) are not used.
+type httpTransport struct {
+	url     string
+	header  http.Header
+	client  *http.Client
+	deliver func([]byte)
+
+	mu        sync.Mutex
+	sessionID string
+	version   string
+}
+
+// NewHTTP returns a client for the Streamable HTTP endpoint at url. header
+// is sent with every request, e.g. for authorization.
+func NewHTTP(url string, header http.Header, opts Options) *Client {
+	c := newClient(opts)
+	c.t = &httpTransport{url: url, header: header, client: &http.Client{}, deliver: c.receive}
+	return c
+}
+
+func (t *httpTransport) setVersion(version string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.version = version
+}
+
+func (t *httpTransport) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range t.header {
+		req.Header[k] = v
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	if t.version != "" {
+		req.Header.Set("MCP-Protocol-Version", t.version)
+	}
+	return req, nil
+}
+
+func (t *httpTransport) send(ctx context.Context, data []byte) error {
+	req, err := t.newRequest(ctx, http.MethodPost, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+	if resp.StatusCode >= 400 {
+		// 404 means the session expired and a new one must be initialized
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if resp.StatusCode == http.StatusAccepted {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		return readEvents(resp.Body, t.deliver)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+	if err != nil {
+		return err
+	}
+	if body = bytes.TrimSpace(body); len(body) > 0 {
+		t.deliver(body)
+	}
+	return nil
+}
+
+// close ends the session on the server, if it has one.
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := t.newRequest(ctx, http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// readEvents passes the data of every event in a server-sent event stream
+// to deliver until the stream ends.
+func readEvents(r io.Reader, deliver func([]byte)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// A blank line dispatches the event
+			if len(data) > 0 {
+				deliver(data)
+			}
+			data = nil
+		case strings.HasPrefix(line, "data:"):
+			if data != nil {
+				data = append(data, '\n')
+			}
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")...)
+		}
+	}
+	if len(data) > 0 {
+		deliver(data)
+	}
+	return scanner.Err()
+}
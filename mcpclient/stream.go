@@ -0,0 +1,121 @@
+package mcpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// maxMessageSize bounds a single message read from a stream.
+const maxMessageSize = 16 * 1024 * 1024
+
+// stopTimeout is how long a server process may take to exit after its
+// stdin is closed before it is killed.
+const stopTimeout = 2 * time.Second
+
+// streamTransport writes newline-delimited messages to w.
+type streamTransport struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer func() error
+}
+
+func (t *streamTransport) send(ctx context.Context, data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := t.w.Write(append(data, '\n'))
+	return err
+}
+
+func (t *streamTransport) close() error {
+	return t.closer()
+}
+
+// readStream passes every line of r to the client until r ends, which ends
+// the connection.
+func (c *Client) readStream(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			c.receive(line)
+		}
+	}
+	err := scanner.Err()
+	if err == nil {
+		err = errors.New("connection closed by server")
+	}
+	c.fail(err)
+}
+
+// Start runs cmd as an MCP server and talks to it over its stdin and
+// stdout. Closing the client closes stdin and stops the process.
+func Start(cmd *exec.Cmd, opts Options) (*Client, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	exited := make(chan struct{})
+	c := newClient(opts)
+	c.t = &streamTransport{
+		w: stdin,
+		closer: func() error {
+			stdin.Close()
+			select {
+			case <-exited:
+			case <-time.After(stopTimeout):
+				cmd.Process.Kill()
+				<-exited
+			}
+			return nil
+		},
+	}
+	go func() {
+		c.readStream(stdout)
+		err := cmd.Wait()
+		if err == nil {
+			err = errors.New("server exited")
+		}
+		c.fail(fmt.Errorf("server process ended: %w", err))
+		close(exited)
+	}()
+	return c, nil
+}
+
+// Dial connects to an MCP server speaking newline-delimited JSON-RPC over
+// TCP, such as "devtool serve --port". tlsCfg may be nil.
+func Dial(ctx context.Context, addr string, tlsCfg *tls.Config, opts Options) (*Client, error) {
+	var conn net.Conn
+	var err error
+	if tlsCfg != nil {
+		dialer := &tls.Dialer{Config: tlsCfg}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := newClient(opts)
+	c.t = &streamTransport{w: conn, closer: conn.Close}
+	go c.readStream(conn)
+	return c, nil
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultBuckets are histogram upper bounds in seconds, suited to tool
+// executions that take from milliseconds to minutes.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+// registry holds devtool's metrics along with the Go runtime and process
+// metrics, apart from the global Prometheus registry.
+var registry = prometheus.NewRegistry()
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Counter is a monotonically increasing value partitioned by labels.
+type Counter struct {
+	vec *prometheus.CounterVec
+}
+
+// NewCounter registers a counter with the given label names.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)}
+	registry.MustRegister(c.vec)
+	return c
+}
+
+// Inc adds one to the counter for the given label values.
+func (c *Counter) Inc(labels ...string) {
+	c.vec.WithLabelValues(labels...).Inc()
+}
+
+// Add adds v to the counter for the given label values.
+func (c *Counter) Add(v float64, labels ...string) {
+	c.vec.WithLabelValues(labels...).Add(v)
+}
+
+// Gauge is a value that can go up and down, partitioned by labels.
+type Gauge struct {
+	vec *prometheus.GaugeVec
+}
+
+// NewGauge registers a gauge with the given label names. A gauge without
+// labels is exported as 0 until it changes.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vec: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)}
+	registry.MustRegister(g.vec)
+	if len(labels) == 0 {
+		g.vec.WithLabelValues()
+	}
+	return g
+}
+
+// Add adds v (which may be negative) to the gauge.
+func (g *Gauge) Add(v float64, labels ...string) {
+	g.vec.WithLabelValues(labels...).Add(v)
+}
+
+func (g *Gauge) Inc(labels ...string) { g.Add(1, labels...) }
+func (g *Gauge) Dec(labels ...string) { g.Add(-1, labels...) }
+
+// Histogram counts observations in cumulative buckets, partitioned by labels.
+type Histogram struct {
+	vec *prometheus.HistogramVec
+}
+
+// NewHistogram registers a histogram with DefaultBuckets.
+func NewHistogram(name, help string, labels ...string) *Histogram {
+	h := &Histogram{vec: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: DefaultBuckets}, labels)}
+	registry.MustRegister(h.vec)
+	return h
+}
+
+// Observe records one value, usually a duration in seconds.
+func (h *Histogram) Observe(v float64, labels ...string) {
+	h.vec.WithLabelValues(labels...).Observe(v)
+}
+
+// Handler serves the registered metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerExposesMetrics(t *testing.T) {
+	calls := NewCounter("test_calls_total", "Calls.", "tool", "outcome")
+	sessions := NewGauge("test_sessions", "Sessions.")
+	latency := NewHistogram("test_latency_seconds", "Latency.", "tool")
+
+	calls.Inc("deploy", "ok")
+	calls.Inc("deploy", "ok")
+	calls.Inc(`say "hi"`, "error")
+	sessions.Inc()
+	sessions.Inc()
+	sessions.Dec()
+	latency.Observe(0.003, "deploy")
+	latency.Observe(0.2, "deploy")
+	latency.Observe(1000, "deploy")
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE test_calls_total counter\n",
+		`test_calls_total{outcome="ok",tool="deploy"} 2` + "\n",
+		`test_calls_total{outcome="error",tool="say \"hi\""} 1` + "\n",
+		"# TYPE test_sessions gauge\ntest_sessions 1\n",
+		"# TYPE test_latency_seconds histogram\n",
+		`test_latency_seconds_bucket{tool="deploy",le="0.005"} 1` + "\n",
+		`test_latency_seconds_bucket{tool="deploy",le="0.25"} 2` + "\n",
+		`test_latency_seconds_bucket{tool="deploy",le="300"} 2` + "\n",
+		`test_latency_seconds_bucket{tool="deploy",le="+Inf"} 3` + "\n",
+		`test_latency_seconds_sum{tool="deploy"} 1000.203` + "\n",
+		`test_latency_seconds_count{tool="deploy"} 3` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+}
@@ -0,0 +1,236 @@
+package rotate
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Writer appends to a file and rotates it once it grows past MaxSize bytes
+// or, with Interval, on the first write of a new interval. Rotated files are
+// renamed to Path.1 (newest) through Path.N; at most MaxBackups are kept.
+// With Compress, rotated files are gzipped to Path.N.gz in the background,
+// and with MaxAge, backups older than that are removed.
+type Writer struct {
+	Path       string
+	MaxSize    int64         // 0 disables size-based rotation
+	Interval   time.Duration // e.g. 24h; intervals are aligned to UTC, 0 disables
+	MaxBackups int
+	MaxAge     time.Duration // 0 keeps backups regardless of age
+	Compress   bool
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time // Interval the current file was started in
+
+	compressing sync.WaitGroup
+}
+
+// New creates a Writer for path. The file is opened on first write.
+func New(path string, maxSizeMB, maxBackups int) *Writer {
+	return &Writer{
+		Path:       path,
+		MaxSize:    int64(maxSizeMB) * 1024 * 1024,
+		MaxBackups: maxBackups,
+	}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	full := w.MaxSize > 0 && w.size+int64(len(p)) > w.MaxSize
+	expired := w.Interval > 0 && !time.Now().Truncate(w.Interval).Equal(w.period)
+	if w.size > 0 && (full || expired) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file and waits for a rotated file to be
+// compressed.
+func (w *Writer) Close() error {
+	defer w.compressing.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// Reopen closes the current file so the next write opens Path again. Use it
+// after an external tool such as logrotate has moved the file away.
+func (w *Writer) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return err
+	}
+	return w.open()
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	// An existing file belongs to the interval it was last written in
+	w.period = time.Now().Truncate(w.Interval)
+	if w.Interval > 0 && w.size > 0 {
+		w.period = info.ModTime().Truncate(w.Interval)
+	}
+	return nil
+}
+
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	// Backups are renamed below, so the previous compression must be done
+	w.compressing.Wait()
+
+	if w.MaxBackups <= 0 {
+		os.Remove(w.Path)
+	} else {
+		w.remove(w.MaxBackups)
+		for i := w.MaxBackups - 1; i >= 1; i-- {
+			if name, gz := w.existing(i); name != "" {
+				os.Rename(name, backupName(w.Path, i+1, gz))
+			}
+		}
+		if err := os.Rename(w.Path, Backup(w.Path, 1)); err != nil {
+			return err
+		}
+		// Compress without holding up writes, which may log to this file
+		if w.Compress {
+			w.compressing.Add(1)
+			go func() {
+				defer w.compressing.Done()
+				if err := compress(Backup(w.Path, 1)); err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to compress %s: %v\n", Backup(w.Path, 1), err)
+				}
+				w.prune()
+			}()
+		} else {
+			w.prune()
+		}
+	}
+
+	return w.open()
+}
+
+// existing returns the name of the i-th backup and whether it is gzipped,
+// or "" if there is none.
+func (w *Writer) existing(i int) (string, bool) {
+	if _, err := os.Stat(Backup(w.Path, i)); err == nil {
+		return Backup(w.Path, i), false
+	}
+	if _, err := os.Stat(Backup(w.Path, i) + ".gz"); err == nil {
+		return Backup(w.Path, i) + ".gz", true
+	}
+	return "", false
+}
+
+func (w *Writer) remove(i int) {
+	os.Remove(Backup(w.Path, i))
+	os.Remove(Backup(w.Path, i) + ".gz")
+}
+
+// prune removes backups older than MaxAge.
+func (w *Writer) prune() {
+	if w.MaxAge <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-w.MaxAge)
+	for i := 1; i <= w.MaxBackups; i++ {
+		name, _ := w.existing(i)
+		if name == "" {
+			continue
+		}
+		if info, err := os.Stat(name); err == nil && info.ModTime().Before(cutoff) {
+			os.Remove(name)
+		}
+	}
+}
+
+// compress replaces name with a gzipped name.gz, keeping its mod time so
+// MaxAge still applies to when the file was last written.
+func compress(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+
+	os.Chtimes(name+".gz", info.ModTime(), info.ModTime())
+	return os.Remove(name)
+}
+
+// Backup returns the name of the i-th rotated file of path (1 is newest).
+// Compressed backups have an additional ".gz" suffix.
+func Backup(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+func backupName(path string, i int, gz bool) string {
+	if gz {
+		return Backup(path, i) + ".gz"
+	}
+	return Backup(path, i)
+}
@@ -0,0 +1,135 @@
+package rotate
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriter_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	w := &Writer{Path: path, MaxSize: 10, MaxBackups: 2}
+	defer w.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	expected := map[string]string{
+		path:            "fourth\n",
+		Backup(path, 1): "third\n",
+		Backup(path, 2): "second\n",
+	}
+	for file, content := range expected {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if string(data) != content {
+			t.Errorf("Expected %s to contain %q, got %q", file, content, data)
+		}
+	}
+
+	if _, err := os.Stat(Backup(path, 3)); !os.IsNotExist(err) {
+		t.Errorf("Expected only 2 backups to be kept")
+	}
+}
+
+func TestWriter_CompressAndMaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devtool.log")
+	w := &Writer{Path: path, MaxSize: 10, MaxBackups: 3, Compress: true, MaxAge: time.Hour}
+	defer w.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	// Compression runs in the background
+	w.Close()
+
+	for i, content := range map[int]string{1: "second\n", 2: "first\n"} {
+		f, err := os.Open(Backup(path, i) + ".gz")
+		if err != nil {
+			t.Fatalf("Expected compressed backup %d: %v", i, err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("Invalid gzip in backup %d: %v", i, err)
+		}
+		data, _ := io.ReadAll(zr)
+		f.Close()
+		if string(data) != content {
+			t.Errorf("Expected backup %d to contain %q, got %q", i, content, data)
+		}
+		if _, err := os.Stat(Backup(path, i)); !os.IsNotExist(err) {
+			t.Errorf("Expected uncompressed backup %d to be removed", i)
+		}
+	}
+
+	// Backups older than MaxAge are dropped on the next rotation
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(Backup(path, 2)+".gz", old, old)
+	w.Write([]byte("fourth\n"))
+	w.Close()
+
+	if _, err := os.Stat(Backup(path, 3) + ".gz"); !os.IsNotExist(err) {
+		t.Errorf("Expected expired backup to be removed")
+	}
+	if _, err := os.Stat(Backup(path, 2) + ".gz"); err != nil {
+		t.Errorf("Expected recent backup to be kept: %v", err)
+	}
+}
+
+func TestWriter_Interval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devtool.log")
+	if err := os.WriteFile(path, []byte("yesterday\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(path, old, old)
+
+	w := &Writer{Path: path, Interval: time.Hour, MaxBackups: 2}
+	defer w.Close()
+	w.Write([]byte("today\n"))
+	w.Write([]byte("again\n"))
+
+	expected := map[string]string{
+		path:            "today\nagain\n",
+		Backup(path, 1): "yesterday\n",
+	}
+	for file, content := range expected {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if string(data) != content {
+			t.Errorf("Expected %s to contain %q, got %q", file, content, data)
+		}
+	}
+}
+
+func TestWriter_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devtool.log")
+	w := New(path, 0, 0)
+	defer w.Close()
+
+	w.Write([]byte("before\n"))
+	if err := os.Rename(path, path+".moved"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reopen(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	w.Write([]byte("after\n"))
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "after\n" {
+		t.Errorf("Expected new file to contain only %q, got %q", "after\n", data)
+	}
+}
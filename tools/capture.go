@@ -0,0 +1,215 @@
+package tools
+
+import (
+	"devtool/config"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// errOutputLimit is returned by a capture's Write once the kept part of the
+// output is full and the rest is not needed, so that producers stop.
+var errOutputLimit = errors.New("output limit reached")
+
+// capture is an io.Writer that keeps at most limit bytes of tool output in
+// memory: the head, the tail or both, depending on the tool's truncate mode.
+// Everything written is counted and, if a spill file is configured, copied
+// there so the full output stays available.
+type capture struct {
+	headLimit int
+	tailLimit int
+	head      []byte
+	tail      []byte // Ring buffer of the last tailLimit bytes
+	tailStart int    // Index of the oldest byte in tail once it is full
+	total     int64
+	stopped   bool   // the producer stopped early, so total is a lower bound
+	drain     bool   // read everything even when the rest is dropped
+	onStop    func() // called when the capture stops its producer
+
+	spill     *os.File
+	spillPath string
+	spillErr  error
+}
+
+// newCapture creates a capture for a tool's output. The primary output is
+// written to a file in the tool's spill_dir, if any, and stops its producer
+// once the limit is reached and the rest is not needed. Other output, such
+// as stderr, is read to the end.
+func newCapture(tool config.ToolConfig, primary bool) *capture {
+	c := &capture{drain: !primary}
+
+	limit := tool.MaxOutputBytes
+	switch {
+	case limit <= 0:
+		c.headLimit = -1
+	case tool.Truncate == "head":
+		c.headLimit = limit
+	case tool.Truncate == "tail":
+		c.tailLimit = limit
+	default:
+		c.headLimit = limit / 2
+		c.tailLimit = limit - c.headLimit
+	}
+
+	if primary && limit > 0 && tool.SpillDir != "" {
+		if err := os.MkdirAll(tool.SpillDir, 0755); err != nil {
+			c.spillErr = err
+		} else if f, err := os.CreateTemp(tool.SpillDir, "devtool-"+sanitizeFileName(tool.Name)+"-*.out"); err != nil {
+			c.spillErr = err
+		} else {
+			c.spill = f
+			c.spillPath = f.Name()
+		}
+	}
+
+	return c
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	if c.limited() {
+		if room := c.headLimit - len(c.head); len(p) > room {
+			c.head = append(c.head, p[:room]...)
+			c.total += int64(room)
+			if !c.stopped {
+				c.stopped = true
+				if c.onStop != nil {
+					c.onStop()
+				}
+			}
+			return room, errOutputLimit
+		}
+	}
+
+	n := len(p)
+	c.total += int64(n)
+
+	if c.spill != nil {
+		if _, err := c.spill.Write(p); err != nil {
+			c.spillErr = err
+			c.spill.Close()
+			os.Remove(c.spillPath)
+			c.spill = nil
+		}
+	}
+
+	if c.headLimit < 0 {
+		c.head = append(c.head, p...)
+		return n, nil
+	}
+
+	if room := c.headLimit - len(c.head); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		c.head = append(c.head, p[:room]...)
+		p = p[room:]
+	}
+
+	if c.tailLimit > 0 && len(p) > 0 {
+		c.writeTail(p)
+	}
+
+	return n, nil
+}
+
+// writeTail adds p to the tail, which keeps the last tailLimit bytes
+// written. Once full, the oldest bytes are overwritten in place rather than
+// the buffer being shifted, so each write costs O(len(p)).
+func (c *capture) writeTail(p []byte) {
+	if len(p) > c.tailLimit {
+		p = p[len(p)-c.tailLimit:]
+	}
+	if room := c.tailLimit - len(c.tail); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		c.tail = append(c.tail, p[:room]...)
+		p = p[room:]
+	}
+	for len(p) > 0 {
+		n := copy(c.tail[c.tailStart:], p)
+		c.tailStart = (c.tailStart + n) % c.tailLimit
+		p = p[n:]
+	}
+}
+
+// tailBytes returns the kept tail in order.
+func (c *capture) tailBytes() []byte {
+	return append(append([]byte{}, c.tail[c.tailStart:]...), c.tail[:c.tailStart]...)
+}
+
+// limited reports whether the capture only keeps part of its input, i.e.
+// whether its producer is stopped once more than limit() bytes were seen.
+func (c *capture) limited() bool {
+	return c.headLimit >= 0 && c.tailLimit == 0 && c.spill == nil && !c.drain
+}
+
+func (c *capture) limit() int {
+	return c.headLimit + c.tailLimit
+}
+
+// spillOutput writes the full output of a tool to a new file in its
+// spill_dir and returns the file's path.
+func spillOutput(tool config.ToolConfig, data []byte) (string, error) {
+	if err := os.MkdirAll(tool.SpillDir, 0755); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(tool.SpillDir, "devtool-"+sanitizeFileName(tool.Name)+"-*.out")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+func (c *capture) truncated() bool {
+	return c.headLimit >= 0 && (c.stopped || c.total > int64(c.headLimit+c.tailLimit))
+}
+
+// String returns the captured output with a truncation marker where bytes
+// were dropped. It also finalizes the spill file, which is kept only when
+// the output was truncated.
+func (c *capture) String() string {
+	if c.spill != nil {
+		c.spill.Close()
+		c.spill = nil
+		if !c.truncated() {
+			os.Remove(c.spillPath)
+			c.spillPath = ""
+		}
+	}
+
+	tail := c.tailBytes()
+	if !c.truncated() {
+		return string(c.head) + string(tail)
+	}
+
+	var b strings.Builder
+	b.Write(c.head)
+	if c.stopped {
+		fmt.Fprintf(&b, "\n... [output truncated after %d bytes] ...\n", c.limit())
+	} else {
+		fmt.Fprintf(&b, "\n... [truncated %d of %d bytes] ...\n", c.total-int64(len(c.head)+len(c.tail)), c.total)
+	}
+	b.Write(tail)
+	if c.spillPath != "" {
+		fmt.Fprintf(&b, "\n[full output saved to %s]", c.spillPath)
+	} else if c.spillErr != nil {
+		fmt.Fprintf(&b, "\n[failed to save full output: %v]", c.spillErr)
+	}
+	return b.String()
+}
+
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, name)
+}
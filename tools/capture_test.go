@@ -0,0 +1,148 @@
+package tools
+
+import (
+	"devtool/config"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteTool_OutputTruncation(t *testing.T) {
+	tool := config.ToolConfig{
+		Name:           "big-tool",
+		Type:           "shell",
+		Command:        "printf 'abcdefghijklmnopqrstuvwxyz'",
+		MaxOutputBytes: 10,
+	}
+
+	cases := map[string]string{
+		"head": "abcdefghij\n... [output truncated after 10 bytes] ...\n",
+		"tail": "\n... [truncated 16 of 26 bytes] ...\nqrstuvwxyz",
+		"":     "abcde\n... [truncated 16 of 26 bytes] ...\nvwxyz",
+	}
+	for mode, expected := range cases {
+		tool.Truncate = mode
+		output, err := ExecuteTool(tool, map[string]interface{}{})
+		// Only head stops the command, which then didn't finish
+		if mode == "head" && !errors.Is(err, errOutputLimit) {
+			t.Errorf("Expected the stopped command to fail with the output limit, got %v", err)
+		} else if mode != "head" && err != nil {
+			t.Fatalf("ExecuteTool failed: %v", err)
+		}
+		if output != expected {
+			t.Errorf("Mode %q: expected output %q, got %q", mode, expected, output)
+		}
+	}
+}
+
+func TestExecuteTool_OutputSpill(t *testing.T) {
+	dir := t.TempDir()
+	tool := config.ToolConfig{
+		Name:           "big-tool",
+		Type:           "shell",
+		Command:        "seq 1 1000",
+		MaxOutputBytes: 20,
+		SpillDir:       dir,
+	}
+
+	output, err := ExecuteTool(tool, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("ExecuteTool failed: %v", err)
+	}
+
+	idx := strings.Index(output, "[full output saved to ")
+	if idx < 0 {
+		t.Fatalf("Expected spill file reference, got %q", output)
+	}
+	path := strings.TrimSuffix(output[idx+len("[full output saved to "):], "]")
+	full, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read spill file: %v", err)
+	}
+	if !strings.HasSuffix(string(full), "999\n1000\n") || len(full) != 3893 {
+		t.Errorf("Spill file does not contain the full output (%d bytes)", len(full))
+	}
+
+	// Untruncated output leaves no file behind
+	tool.Command = "echo small"
+	if _, err := ExecuteTool(tool, map[string]interface{}{}); err != nil {
+		t.Fatalf("ExecuteTool failed: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the truncated output to be spilled, found %d files", len(entries))
+	}
+}
+
+func TestExecuteTool_ShellStopsAtLimit(t *testing.T) {
+	tool := config.ToolConfig{
+		Name:           "endless",
+		Type:           "shell",
+		Command:        "yes",
+		MaxOutputBytes: 6,
+		Truncate:       "head",
+	}
+
+	done := make(chan struct{})
+	var output string
+	var err error
+	go func() {
+		output, err = ExecuteTool(tool, map[string]interface{}{})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Command was not stopped at the output limit")
+	}
+	if !errors.Is(err, errOutputLimit) {
+		t.Errorf("Expected the stopped command to fail with the output limit, got %v", err)
+	}
+	expected := "y\ny\ny\n\n... [output truncated after 6 bytes] ...\n"
+	if output != expected {
+		t.Errorf("Expected output %q, got %q", expected, output)
+	}
+}
+
+func TestExecuteTool_HTTP_StopsReadingAtLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("x", 1<<20))
+	}))
+	defer ts.Close()
+
+	tool := config.ToolConfig{
+		Name:           "http-big",
+		URL:            ts.URL,
+		Method:         "GET",
+		MaxOutputBytes: 8,
+		Truncate:       "head",
+	}
+
+	output, err := ExecuteTool(tool, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("ExecuteTool failed: %v", err)
+	}
+
+	expected := "xxxxxxxx\n... [output truncated after 8 bytes] ...\n"
+	if output != expected {
+		t.Errorf("Expected output %q, got %q", expected, output)
+	}
+}
+
+func TestCaptureTail(t *testing.T) {
+	c := newCapture(config.ToolConfig{MaxOutputBytes: 5, Truncate: "tail"}, true)
+	var all string
+	for _, chunk := range []string{"ab", "cde", "f", "ghij", "klmnopq", "r"} {
+		c.Write([]byte(chunk))
+		all += chunk
+	}
+	if got, want := string(c.tailBytes()), all[len(all)-5:]; got != want {
+		t.Errorf("Expected tail %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"devtool/config"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxCompletions is the most values Complete returns, the limit of MCP's
+// completion/complete.
+const MaxCompletions = 100
+
+// ToolRunner runs the tool called name to complete a value. It returns an
+// error for tools that may not be used for completion.
+type ToolRunner func(ctx context.Context, name string, args map[string]interface{}) (*Result, error)
+
+// RunAvailable returns a ToolRunner for the read-only tools in available.
+func RunAvailable(available []config.ToolConfig) ToolRunner {
+	return func(ctx context.Context, name string, args map[string]interface{}) (*Result, error) {
+		for _, t := range available {
+			if t.Name == name && t.Hints().ReadOnly {
+				return RunTool(ctx, t, args)
+			}
+		}
+		return &Result{}, fmt.Errorf("not available for completion")
+	}
+}
+
+// Complete returns suggested values for p that start with prefix, ignoring
+// case, and the total number of matches, which may exceed MaxCompletions.
+// args holds arguments the user already gave to declared parameters; they
+// are passed on to completion commands and tools. Completion tools are run
+// by run.
+func Complete(ctx context.Context, p config.Parameter, run ToolRunner, prefix string, args map[string]interface{}) ([]string, int, error) {
+	if p.Complete == nil {
+		return nil, 0, nil
+	}
+
+	candidates, err := completionCandidates(ctx, *p.Complete, run, prefix, args)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	seen := make(map[string]bool)
+	var matches []string
+	lower := strings.ToLower(prefix)
+	for _, c := range candidates {
+		if c == "" || seen[c] || !strings.HasPrefix(strings.ToLower(c), lower) {
+			continue
+		}
+		seen[c] = true
+		matches = append(matches, c)
+	}
+	sort.Strings(matches)
+
+	total := len(matches)
+	if total > MaxCompletions {
+		matches = matches[:MaxCompletions]
+	}
+	return matches, total, nil
+}
+
+func completionCandidates(ctx context.Context, c config.CompleteConfig, run ToolRunner, prefix string, args map[string]interface{}) ([]string, error) {
+	values := append([]string{}, c.Values...)
+
+	if c.Command != "" {
+		tool := config.ToolConfig{Name: "completion", Type: "shell", Command: c.Command}
+		cmdArgs := make(map[string]interface{}, len(args)+1)
+		for k, v := range args {
+			cmdArgs[k] = v
+			tool.Parameters = append(tool.Parameters, config.Parameter{Name: k, Type: "string"})
+		}
+		cmdArgs["prefix"] = prefix
+		tool.Parameters = append(tool.Parameters, config.Parameter{Name: "prefix", Type: "string"})
+
+		res, err := RunTool(ctx, tool, cmdArgs)
+		if err != nil {
+			return nil, fmt.Errorf("completion command failed: %w", err)
+		}
+		values = append(values, splitLines(res.Output)...)
+	}
+
+	if c.Tool != "" {
+		res, err := run(ctx, c.Tool, args)
+		if err != nil {
+			return nil, fmt.Errorf("completion tool '%s' failed: %w", c.Tool, err)
+		}
+		if c.Path == "" {
+			values = append(values, splitLines(res.Output)...)
+		} else {
+			var doc interface{}
+			if err := json.Unmarshal([]byte(res.Output), &doc); err != nil {
+				return nil, fmt.Errorf("completion tool '%s' did not return JSON: %w", c.Tool, err)
+			}
+			found, err := evalJSONPath(doc, c.Path)
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range found {
+				values = append(values, fmt.Sprintf("%v", v))
+			}
+		}
+	}
+
+	return values, nil
+}
+
+func splitLines(s string) []string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"context"
+	"devtool/config"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"items": [{"name": "deploy-api"}, {"name": "deploy-web"}, {"name": "build"}]}`))
+	}))
+	defer server.Close()
+	available := []config.ToolConfig{
+		{Name: "list-pipelines", URL: server.URL, Method: "GET"},
+		{Name: "create-pipeline", URL: server.URL, Method: "POST"},
+	}
+
+	tests := []struct {
+		name     string
+		complete config.CompleteConfig
+		prefix   string
+		expected []string
+	}{
+		{"static", config.CompleteConfig{Values: []string{"prod", "staging", "Preview"}}, "p", []string{"Preview", "prod"}},
+		{"command", config.CompleteConfig{Command: `printf "orders\nusers\n$PREFIX-new\n"`}, "u", []string{"u-new", "users"}},
+		{"tool", config.CompleteConfig{Tool: "list-pipelines", Path: "$.items[*].name"}, "deploy", []string{"deploy-api", "deploy-web"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := config.Parameter{Name: "x", Complete: &tt.complete}
+			values, total, err := Complete(context.Background(), p, RunAvailable(available), tt.prefix, nil)
+			if err != nil {
+				t.Fatalf("Complete failed: %v", err)
+			}
+			if !reflect.DeepEqual(values, tt.expected) || total != len(tt.expected) {
+				t.Errorf("Expected %v, got %v (total %d)", tt.expected, values, total)
+			}
+		})
+	}
+
+	// Tools that aren't available or may have side effects can't be used
+	// for completion
+	for _, name := range []string{"drop-db", "create-pipeline"} {
+		p := config.Parameter{Name: "x", Complete: &config.CompleteConfig{Tool: name}}
+		if _, _, err := Complete(context.Background(), p, RunAvailable(available), "", nil); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
@@ -2,48 +2,348 @@ package tools
 
 import (
 	"bytes"
+	"context"
 	"devtool/config"
+	"devtool/tracing"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
+// Result is the outcome of a tool execution.
+type Result struct {
+	Output    string // Standard output, or the HTTP response body
+	Stderr    string // Standard error of shell tools
+	ExitCode  int    // Exit code of shell tools
+	MimeType  string // Declared type, HTTP Content-Type or detected type of Output, if known
+	Truncated bool   // Output was cut to the tool's output limit
+	Stopped   bool   // The command was stopped at the output limit before it finished
+
+	Structured interface{} // Structured content returned by MCP tools
+}
+
+// ExecuteTool runs a tool and returns its output.
 func ExecuteTool(tool config.ToolConfig, args map[string]interface{}) (string, error) {
+	res, err := RunTool(context.Background(), tool, args)
+	return res.Output, err
+}
+
+// RunTool runs a tool and returns its full result. The result is never nil,
+// even when an error is returned, so partial output can be reported. Output
+// is capped at config.DefaultMaxOutputBytes unless the tool sets a limit.
+func RunTool(ctx context.Context, tool config.ToolConfig, args map[string]interface{}) (*Result, error) {
+	if tool.MaxOutputBytes == 0 {
+		tool.MaxOutputBytes = config.DefaultMaxOutputBytes
+	}
+	typ := toolType(tool)
+	release, err := acquireQuota(tool)
+	if err != nil {
+		executionsTotal.Inc(tool.Name, typ, "rate_limited")
+		return &Result{}, err
+	}
+	defer release()
+
+	ctx, span := tracing.Start(ctx, "execute "+tool.Name, tracing.KindInternal)
+	span.SetAttribute("tool.name", tool.Name)
+	span.SetAttribute("tool.type", typ)
+	defer span.Finish()
+
+	start := time.Now()
+	var res *Result
 	if tool.Type == "shell" {
-		return executeShellTool(tool, args)
+		res, err = executeShellTool(ctx, tool, args)
+		span.SetAttribute("process.exit_code", res.ExitCode)
+	} else if tool.Type == "mcp" {
+		res, err = executeMCPTool(ctx, tool, args)
+	} else {
+		// Default to HTTP
+		res, err = executeHTTPTool(ctx, tool, args)
 	}
-	// Default to HTTP
-	return executeHTTPTool(tool, args)
+	res.MimeType = outputMimeType(tool, res)
+	span.RecordError(err)
+
+	executionSeconds.Observe(time.Since(start).Seconds(), tool.Name, typ)
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	executionsTotal.Inc(tool.Name, typ, outcome)
+	return res, err
 }
 
-func executeShellTool(tool config.ToolConfig, args map[string]interface{}) (string, error) {
-	// We run the command using sh -c to allow for complex commands
-	cmd := exec.Command("sh", "-c", tool.Command)
+// killWaitDelay bounds how long a killed command's output is drained.
+const killWaitDelay = time.Second
+
+func executeShellTool(ctx context.Context, tool config.ToolConfig, args map[string]interface{}) (*Result, error) {
+	res := &Result{}
+
+	// The command is killed once stdout is full and the rest is not needed.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd, err := buildShellCommand(ctx, tool, args)
+	if err != nil {
+		return res, err
+	}
+
+	cmd.Env = shellEnv(tool, args)
+	if tp := tracing.Traceparent(ctx); tp != "" {
+		cmd.Env = append(cmd.Env, "TRACEPARENT="+tp)
+	}
+	if tool.WorkDir != "" {
+		cmd.Dir = expandParams(tool.WorkDir, args, tool.Parameters)
+	}
+	if tool.Stdin != "" {
+		cmd.Stdin = strings.NewReader(expandParams(tool.Stdin, args, tool.Parameters))
+	}
+
+	stdout := newCapture(tool, true)
+	stdout.onStop = cancel
+	stderr := newCapture(tool, false)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Children of a killed shell may still hold its output open
+	cmd.WaitDelay = killWaitDelay
+
+	err = cmd.Run()
+	res.Output = stdout.String()
+	res.Stderr = stderr.String()
+	res.Truncated = stdout.truncated()
+	res.Stopped = stdout.stopped
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(err, &exitErr):
+			res.ExitCode = exitErr.ExitCode()
+		case !res.Stopped:
+			res.ExitCode = -1
+			return res, fmt.Errorf("command execution failed: %w", err)
+		default:
+			res.ExitCode = -1
+		}
+	}
 
-	// Prepare environment variables
-	env := os.Environ()
-	for k, v := range args {
-		// Convert value to string
-		valStr := fmt.Sprintf("%v", v)
+	// A command stopped at the output limit didn't finish, so it never
+	// counts as a success, whatever its exit status.
+	if res.Stopped {
+		return res, fmt.Errorf("command stopped: %w after %d bytes (exit code %d)", errOutputLimit, stdout.limit(), res.ExitCode)
+	}
+
+	if !isSuccessExitCode(tool, res.ExitCode) {
+		return res, fmt.Errorf("command exited with code %d", res.ExitCode)
+	}
+
+	return res, nil
+}
+
+func isSuccessExitCode(tool config.ToolConfig, code int) bool {
+	if len(tool.SuccessExitCodes) == 0 {
+		return code == 0
+	}
+	for _, c := range tool.SuccessExitCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// shellEnv builds the environment of a shell tool: the inherited server
+// variables, then the arguments of declared parameters, then the tool's own
+// env entries. Other arguments are ignored, so that callers can't override
+// variables such as PATH or LD_PRELOAD.
+func shellEnv(tool config.ToolConfig, args map[string]interface{}) []string {
+	var env []string
+	switch {
+	case tool.InheritEnv == nil || tool.InheritEnv.All:
+		env = os.Environ()
+	default:
+		for _, name := range tool.InheritEnv.Allow {
+			if val, ok := os.LookupEnv(name); ok {
+				env = append(env, fmt.Sprintf("%s=%s", name, val))
+			}
+		}
+	}
+
+	for _, p := range tool.Parameters {
+		v, ok := args[p.Name]
+		if !ok {
+			continue
+		}
 		// Sanitize key to be upper case and replace - with _
-		key := strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
-		env = append(env, fmt.Sprintf("%s=%s", key, valStr))
+		key := strings.ToUpper(strings.ReplaceAll(p.Name, "-", "_"))
+		env = append(env, fmt.Sprintf("%s=%v", key, v))
+	}
+
+	// Expand server variables before parameters so argument values are never
+	// subject to $VAR expansion.
+	for k, v := range tool.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, expandParams(os.ExpandEnv(v), args, tool.Parameters)))
 	}
-	cmd.Env = env
 
-	output, err := cmd.CombinedOutput()
+	return env
+}
+
+// buildShellCommand creates the command for a shell tool. An "args" list is
+// executed directly, so templated values are never interpreted by a shell;
+// otherwise Command is handed to the configured interpreter. Sandboxed tools
+// are wrapped accordingly.
+func buildShellCommand(ctx context.Context, tool config.ToolConfig, args map[string]interface{}) (*exec.Cmd, error) {
+	argv, err := shellArgv(tool, args)
 	if err != nil {
-		return string(output), fmt.Errorf("command execution failed: %w", err)
+		return nil, err
 	}
 
-	return string(output), nil
+	if tool.Sandbox != nil {
+		argv, err = sandboxArgv(tool, argv, expandParams(tool.WorkDir, args, tool.Parameters))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return exec.CommandContext(ctx, argv[0], argv[1:]...), nil
 }
 
-func executeHTTPTool(tool config.ToolConfig, args map[string]interface{}) (string, error) {
+func shellArgv(tool config.ToolConfig, args map[string]interface{}) ([]string, error) {
+	if len(tool.Args) > 0 {
+		if tool.Command != "" {
+			return nil, fmt.Errorf("tool '%s' sets both command and args", tool.Name)
+		}
+
+		argv := make([]string, len(tool.Args))
+		for i, a := range tool.Args {
+			argv[i] = expandParams(a, args, tool.Parameters)
+		}
+		return argv, nil
+	}
+
+	if tool.Command == "" {
+		return nil, fmt.Errorf("tool '%s' has no command", tool.Name)
+	}
+
+	interpreter := tool.Interpreter
+	if interpreter == "" {
+		interpreter = "sh"
+	}
+	argv := append([]string{interpreter}, interpreterFlags(interpreter)...)
+	return append(argv, tool.Command), nil
+}
+
+// interpreterFlags returns the flags that make an interpreter run a script
+// passed as the next argument.
+func interpreterFlags(interpreter string) []string {
+	name := strings.TrimSuffix(filepath.Base(interpreter), ".exe")
+	switch name {
+	case "pwsh", "powershell":
+		return []string{"-NoProfile", "-NonInteractive", "-Command"}
+	case "node", "ruby", "perl":
+		return []string{"-e"}
+	default:
+		// sh, bash, zsh, python, python3, ...
+		return []string{"-c"}
+	}
+}
+
+func executeHTTPTool(ctx context.Context, tool config.ToolConfig, args map[string]interface{}) (*Result, error) {
+	if tool.Pagination != nil {
+		output, truncated, err := executePaginatedHTTPTool(ctx, tool, args)
+		if err != nil && !truncated {
+			// The body of a failed page is returned as is
+			out := newCapture(tool, false)
+			out.Write([]byte(output))
+			output, truncated = out.String(), out.truncated()
+		}
+		return &Result{Output: output, MimeType: "application/json", Truncated: truncated}, err
+	}
+
+	resp, err := sendHTTPRequest(ctx, tool, tool.URL, args)
+	if err != nil {
+		return &Result{}, err
+	}
+	defer resp.Body.Close()
+	mimeType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	out := newCapture(tool, true)
+
+	// The copy stops once the kept part is full unless the rest is needed
+	// for the tail or the spill file.
+	_, err = io.Copy(out, resp.Body)
+	res := &Result{Output: out.String(), MimeType: mimeType, Truncated: out.truncated()}
+	if err != nil && !out.stopped {
+		return res, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return res, fmt.Errorf("server returned error status: %d", resp.StatusCode)
+	}
+
+	return res, nil
+}
+
+// outputMimeType returns the declared MIME type of a tool's output, or the
+// one already known. Declared binary outputs without a MIME type are
+// detected from their content.
+func outputMimeType(tool config.ToolConfig, res *Result) string {
+	if tool.Output == nil {
+		return res.MimeType
+	}
+	if tool.Output.MimeType != "" {
+		return tool.Output.MimeType
+	}
+	switch tool.Output.Type {
+	case "image", "audio", "resource":
+		if res.MimeType == "" && res.Output != "" {
+			detected, _, _ := mime.ParseMediaType(http.DetectContentType([]byte(res.Output)))
+			return detected
+		}
+	case "json":
+		return "application/json"
+	}
+	return res.MimeType
+}
+
+// doHTTPRequest performs a single request for an HTTP tool against url and
+// returns the complete response body and headers. Bodies larger than the
+// tool's max_output_bytes are refused rather than read into memory.
+func doHTTPRequest(ctx context.Context, tool config.ToolConfig, url string, args map[string]interface{}) ([]byte, http.Header, error) {
+	resp, err := sendHTTPRequest(ctx, tool, url, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	var body io.Reader = resp.Body
+	if tool.MaxOutputBytes > 0 {
+		body = io.LimitReader(resp.Body, int64(tool.MaxOutputBytes)+1)
+	}
+	respBody, err := io.ReadAll(body)
+	if err != nil {
+		return nil, resp.Header, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if tool.MaxOutputBytes > 0 && len(respBody) > tool.MaxOutputBytes {
+		return nil, resp.Header, fmt.Errorf("response body exceeds max_output_bytes (%d)", tool.MaxOutputBytes)
+	}
+
+	if resp.StatusCode >= 400 {
+		return respBody, resp.Header, fmt.Errorf("server returned error status: %d", resp.StatusCode)
+	}
+
+	return respBody, resp.Header, nil
+}
+
+// sendHTTPRequest sends a request for an HTTP tool. The caller must close
+// the response body, which ends the request's span so that it covers
+// reading the body too.
+func sendHTTPRequest(ctx context.Context, tool config.ToolConfig, url string, args map[string]interface{}) (*http.Response, error) {
 	// 1. Prepare URL
 	// Simple implementation: assume URL doesn't need path param substitution for now,
 	// or we could use a library for that. Let's stick to simple.
@@ -53,14 +353,18 @@ func executeHTTPTool(tool config.ToolConfig, args map[string]interface{}) (strin
 	if tool.Method == "POST" || tool.Method == "PUT" || tool.Method == "PATCH" {
 		jsonBody, err := json.Marshal(args)
 		if err != nil {
-			return "", fmt.Errorf("failed to marshal args: %w", err)
+			return nil, fmt.Errorf("failed to marshal args: %w", err)
 		}
 		bodyReader = bytes.NewBuffer(jsonBody)
 	}
 
-	req, err := http.NewRequest(tool.Method, tool.URL, bodyReader)
+	ctx, span := tracing.Start(ctx, "HTTP "+tool.Method, tracing.KindClient)
+
+	req, err := http.NewRequestWithContext(ctx, tool.Method, url, bodyReader)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		span.RecordError(err)
+		span.Finish()
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// 3. Set Headers
@@ -80,22 +384,42 @@ func executeHTTPTool(tool config.ToolConfig, args map[string]interface{}) (strin
 		req.URL.RawQuery = q.Encode()
 	}
 
-	// 5. Execute
+	// 5. Propagate the trace
+	if tp := tracing.Traceparent(ctx); tp != "" {
+		req.Header.Set("traceparent", tp)
+	}
+	span.SetAttribute("http.request.method", tool.Method)
+	span.SetAttribute("url.full", req.URL.Redacted())
+
+	// 6. Execute
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		span.RecordError(err)
+		span.Finish()
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
-	defer resp.Body.Close()
+	span.SetAttribute("http.response.status_code", resp.StatusCode)
+	resp.Body = &tracedBody{ReadCloser: resp.Body, span: span}
+	return resp, nil
+}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
-	}
+// tracedBody ends an HTTP request's span when the response body is closed.
+type tracedBody struct {
+	io.ReadCloser
+	span *tracing.Span
+}
 
-	if resp.StatusCode >= 400 {
-		return string(respBody), fmt.Errorf("server returned error status: %d", resp.StatusCode)
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.span.RecordError(err)
 	}
+	return n, err
+}
 
-	return string(respBody), nil
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.span.Finish()
+	return err
 }
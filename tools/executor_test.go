@@ -1,13 +1,18 @@
 package tools
 
 import (
+	"context"
 	"devtool/config"
+	"devtool/tracing"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestExecuteTool_Shell(t *testing.T) {
@@ -15,6 +20,9 @@ func TestExecuteTool_Shell(t *testing.T) {
 		Name:    "echo-tool",
 		Type:    "shell",
 		Command: "echo $MESSAGE", // use $MESSAGE to test env var passing
+		Parameters: []config.Parameter{
+			{Name: "message", Type: "string"},
+		},
 	}
 
 	args := map[string]interface{}{
@@ -163,3 +171,241 @@ func TestExecuteTool_HTTP_Error(t *testing.T) {
 		t.Errorf("Expected error to contain 500, got %v", err)
 	}
 }
+
+func TestExecuteTool_ShellArgs(t *testing.T) {
+	tool := config.ToolConfig{
+		Name: "argv-tool",
+		Type: "shell",
+		Args: []string{"printf", "%s|%s", "--author={{author}}", "{{since}}"},
+		Parameters: []config.Parameter{
+			{Name: "author"},
+			{Name: "since"},
+		},
+	}
+
+	// Shell metacharacters must reach the program verbatim.
+	args := map[string]interface{}{
+		"author": "bob; echo pwned $(id)",
+	}
+
+	output, err := ExecuteTool(tool, args)
+	if err != nil {
+		t.Fatalf("ExecuteTool failed: %v", err)
+	}
+
+	expected := "--author=bob; echo pwned $(id)|"
+	if output != expected {
+		t.Errorf("Expected output '%s', got '%s'", expected, output)
+	}
+}
+
+func TestExecuteTool_ShellInterpreter(t *testing.T) {
+	tool := config.ToolConfig{
+		Name:        "bash-tool",
+		Type:        "shell",
+		Interpreter: "bash",
+		Command:     "arr=(a b c); echo ${#arr[@]}",
+	}
+
+	output, err := ExecuteTool(tool, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("ExecuteTool failed: %v", err)
+	}
+
+	if output != "3\n" {
+		t.Errorf("Expected output '3\\n', got '%s'", output)
+	}
+}
+
+func TestExecuteTool_ShellEnvironment(t *testing.T) {
+	t.Setenv("DEVTOOL_TEST_SECRET", "leaked")
+	t.Setenv("DEVTOOL_TEST_ALLOWED", "visible")
+
+	dir := t.TempDir()
+	tool := config.ToolConfig{
+		Name:       "env-tool",
+		Type:       "shell",
+		Command:    "pwd; echo \"$DEVTOOL_TEST_SECRET|$DEVTOOL_TEST_ALLOWED|$GREETING\"; cat",
+		WorkDir:    dir,
+		InheritEnv: &config.EnvInheritance{Allow: []string{"PATH", "DEVTOOL_TEST_ALLOWED"}},
+		Env:        map[string]string{"GREETING": "hi {{name}}"},
+		Stdin:      "payload for {{name}}",
+		Parameters: []config.Parameter{{Name: "name"}},
+	}
+
+	output, err := ExecuteTool(tool, map[string]interface{}{"name": "$HOME"})
+	if err != nil {
+		t.Fatalf("ExecuteTool failed: %v", err)
+	}
+
+	expected := dir + "\n|visible|hi $HOME\npayload for $HOME"
+	if output != expected {
+		t.Errorf("Expected output '%s', got '%s'", expected, output)
+	}
+}
+
+func TestExecuteTool_ShellEnvironmentUndeclared(t *testing.T) {
+	tool := config.ToolConfig{
+		Name:       "env-tool",
+		Type:       "shell",
+		Command:    "echo \"$NAME|$BASH_ENV|$LD_PRELOAD\"",
+		Parameters: []config.Parameter{{Name: "name"}},
+	}
+
+	output, err := ExecuteTool(tool, map[string]interface{}{
+		"name":       "x",
+		"bash_env":   "/tmp/evil",
+		"LD_PRELOAD": "/tmp/evil.so",
+	})
+	if err != nil {
+		t.Fatalf("ExecuteTool failed: %v", err)
+	}
+	if output != "x||\n" {
+		t.Errorf("Expected only declared parameters to be exported, got %q", output)
+	}
+}
+
+func TestRunTool_ShellStreams(t *testing.T) {
+	tool := config.ToolConfig{
+		Name:             "grep-tool",
+		Type:             "shell",
+		Command:          "echo out; echo err >&2; exit 1",
+		SuccessExitCodes: []int{0, 1},
+	}
+
+	res, err := RunTool(context.Background(), tool, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("RunTool failed: %v", err)
+	}
+
+	if res.Output != "out\n" {
+		t.Errorf("Expected stdout 'out\\n', got '%s'", res.Output)
+	}
+	if res.Stderr != "err\n" {
+		t.Errorf("Expected stderr 'err\\n', got '%s'", res.Stderr)
+	}
+	if res.ExitCode != 1 {
+		t.Errorf("Expected exit code 1, got %d", res.ExitCode)
+	}
+
+	tool.SuccessExitCodes = nil
+	res, err = RunTool(context.Background(), tool, map[string]interface{}{})
+	if err == nil || !strings.Contains(err.Error(), "code 1") {
+		t.Errorf("Expected exit code error, got %v", err)
+	}
+	if res.ExitCode != 1 {
+		t.Errorf("Expected exit code 1, got %d", res.ExitCode)
+	}
+}
+
+func TestRunTool_TracePropagation(t *testing.T) {
+	shutdown := tracing.SetExporter(tracetest.NewInMemoryExporter(), "test")
+	defer shutdown()
+
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("traceparent")
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	ctx, span := tracing.Start(context.Background(), "test", tracing.KindInternal)
+	defer span.Finish()
+	traceID := tracing.Traceparent(ctx)[3:35]
+
+	if _, err := RunTool(ctx, config.ToolConfig{Name: "ping", URL: server.URL, Method: "GET"}, nil); err != nil {
+		t.Fatalf("HTTP tool failed: %v", err)
+	}
+	if sc, err := tracing.ParseTraceparent(header); err != nil || sc.TraceID().String() != traceID {
+		t.Errorf("Expected traceparent in trace %s, got %q", traceID, header)
+	}
+
+	res, err := RunTool(ctx, config.ToolConfig{Name: "env", Type: "shell", Command: "echo $TRACEPARENT"}, nil)
+	if err != nil {
+		t.Fatalf("Shell tool failed: %v", err)
+	}
+	if !strings.Contains(res.Output, traceID) {
+		t.Errorf("Expected TRACEPARENT in trace %s, got %q", traceID, res.Output)
+	}
+}
+
+// keptSpans keeps exported spans after shutdown, which the SDK's in-memory
+// exporter discards.
+type keptSpans struct {
+	*tracetest.InMemoryExporter
+}
+
+func (keptSpans) Shutdown(context.Context) error { return nil }
+
+func TestRunTool_HTTPSpanCoversBody(t *testing.T) {
+	exp := keptSpans{tracetest.NewInMemoryExporter()}
+	shutdown := tracing.SetExporter(exp, "test")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(" second"))
+	}))
+	defer server.Close()
+
+	res, err := RunTool(context.Background(), config.ToolConfig{Name: "slow", URL: server.URL, Method: "GET"}, nil)
+	shutdown()
+	if err != nil || res.Output != "first second" {
+		t.Fatalf("Unexpected result %+v, %v", res, err)
+	}
+
+	for _, span := range exp.GetSpans() {
+		if span.Name == "HTTP GET" {
+			if d := span.EndTime.Sub(span.StartTime); d < 100*time.Millisecond {
+				t.Errorf("Expected the HTTP span to include reading the body, lasted %s", d)
+			}
+			return
+		}
+	}
+	t.Errorf("Expected an HTTP GET span")
+}
+
+func TestRunTool_OutputMimeType(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf; name=report.pdf")
+		w.Write([]byte("%PDF-1.7\n"))
+	}))
+	defer ts.Close()
+
+	res, err := RunTool(context.Background(), config.ToolConfig{Name: "report", Method: "GET", URL: ts.URL}, nil)
+	if err != nil {
+		t.Fatalf("RunTool failed: %v", err)
+	}
+	if res.MimeType != "application/pdf" {
+		t.Errorf("Expected the Content-Type without params, got %q", res.MimeType)
+	}
+
+	// Declared binary outputs are detected from their content
+	png := config.ToolConfig{
+		Name:    "graph",
+		Type:    "shell",
+		Command: `printf '\211PNG\r\n\032\n'`,
+		Output:  &config.OutputConfig{Type: "image"},
+	}
+	res, err = RunTool(context.Background(), png, nil)
+	if err != nil {
+		t.Fatalf("RunTool failed: %v", err)
+	}
+	if res.MimeType != "image/png" {
+		t.Errorf("Expected image/png to be detected, got %q", res.MimeType)
+	}
+
+	png.Output.MimeType = "image/x-custom"
+	res, _ = RunTool(context.Background(), png, nil)
+	if res.MimeType != "image/x-custom" {
+		t.Errorf("Expected the declared MIME type, got %q", res.MimeType)
+	}
+
+	// Truncation is reported so binary output isn't returned corrupted
+	png.MaxOutputBytes = 4
+	res, _ = RunTool(context.Background(), png, nil)
+	if !res.Truncated {
+		t.Errorf("Expected the output to be marked truncated")
+	}
+}
@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// evalJSONPath evaluates a small subset of JSONPath against a decoded JSON
+// document. Supported syntax: "$" (root), ".key", "['key']", "[n]", "[*]"
+// and ".*". It returns every matching value.
+func evalJSONPath(doc interface{}, path string) ([]interface{}, error) {
+	path = strings.TrimSpace(path)
+	if path == "" || path == "$" {
+		return []interface{}{doc}, nil
+	}
+	if !strings.HasPrefix(path, "$") {
+		path = "$." + path
+	}
+
+	current := []interface{}{doc}
+	rest := path[1:]
+	for rest != "" {
+		var next []interface{}
+		switch {
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: missing ']'", path)
+			}
+			selector := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+
+			switch {
+			case selector == "*":
+				for _, v := range current {
+					next = append(next, children(v)...)
+				}
+			case strings.HasPrefix(selector, "'") || strings.HasPrefix(selector, "\""):
+				key := strings.Trim(selector, "'\"")
+				for _, v := range current {
+					if m, ok := v.(map[string]interface{}); ok {
+						if child, ok := m[key]; ok {
+							next = append(next, child)
+						}
+					}
+				}
+			default:
+				idx, err := strconv.Atoi(selector)
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSONPath %q: bad index %q", path, selector)
+				}
+				for _, v := range current {
+					if arr, ok := v.([]interface{}); ok {
+						i := idx
+						if i < 0 {
+							i += len(arr)
+						}
+						if i >= 0 && i < len(arr) {
+							next = append(next, arr[i])
+						}
+					}
+				}
+			}
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			rest = rest[end:]
+			if key == "" {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty key", path)
+			}
+
+			for _, v := range current {
+				if key == "*" {
+					next = append(next, children(v)...)
+					continue
+				}
+				if m, ok := v.(map[string]interface{}); ok {
+					if child, ok := m[key]; ok {
+						next = append(next, child)
+					}
+				}
+			}
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", path, rest)
+		}
+		current = next
+	}
+
+	return current, nil
+}
+
+func children(v interface{}) []interface{} {
+	switch t := v.(type) {
+	case []interface{}:
+		return t
+	case map[string]interface{}:
+		out := make([]interface{}, 0, len(t))
+		for _, child := range t {
+			out = append(out, child)
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"devtool/config"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitError is returned when a tool is called more often than its
+// rate_limit or max_concurrent settings allow. Calls are rejected rather
+// than queued; clients should retry after RetryAfter.
+type RateLimitError struct {
+	Tool       string
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("tool '%s' %s; retry after %s", e.Tool, e.Reason, e.RetryAfter)
+}
+
+// limiter tracks the quota of one tool across all callers in the process.
+type limiter struct {
+	perMinute     int
+	burst         int
+	maxConcurrent int
+
+	tokens  float64
+	last    time.Time
+	running int
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*limiter)
+)
+
+// acquireQuota reserves one execution of tool. The returned release func
+// must be called when the execution ends.
+func acquireQuota(tool config.ToolConfig) (func(), error) {
+	if tool.RateLimit == nil && tool.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+
+	perMinute, burst := 0, 0
+	if tool.RateLimit != nil && tool.RateLimit.PerMinute > 0 {
+		perMinute = tool.RateLimit.PerMinute
+		burst = tool.RateLimit.Burst
+		if burst <= 0 {
+			burst = 1
+		}
+	}
+
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	now := time.Now()
+	l, ok := limiters[tool.Name]
+	if !ok {
+		l = &limiter{}
+		limiters[tool.Name] = l
+	}
+	if !ok || l.perMinute != perMinute || l.burst != burst || l.maxConcurrent != tool.MaxConcurrent {
+		// New tool or changed settings (config reload): start with a full
+		// bucket but keep counting running executions
+		l.perMinute = perMinute
+		l.burst = burst
+		l.maxConcurrent = tool.MaxConcurrent
+		l.tokens = float64(burst)
+		l.last = now
+	}
+
+	if l.maxConcurrent > 0 && l.running >= l.maxConcurrent {
+		return nil, &RateLimitError{
+			Tool:       tool.Name,
+			Reason:     fmt.Sprintf("is already running %d times (max_concurrent)", l.running),
+			RetryAfter: time.Second,
+		}
+	}
+
+	if l.perMinute > 0 {
+		rate := float64(l.perMinute) / 60 // tokens per second
+		l.tokens = math.Min(float64(l.burst), l.tokens+now.Sub(l.last).Seconds()*rate)
+		l.last = now
+
+		if l.tokens < 1 {
+			wait := time.Duration((1 - l.tokens) / rate * float64(time.Second))
+			return nil, &RateLimitError{
+				Tool:       tool.Name,
+				Reason:     fmt.Sprintf("exceeded its rate limit of %d calls per minute", l.perMinute),
+				RetryAfter: wait.Round(time.Second) + time.Second,
+			}
+		}
+		l.tokens--
+	}
+
+	l.running++
+	return func() {
+		limitersMu.Lock()
+		defer limitersMu.Unlock()
+		l.running--
+	}, nil
+}
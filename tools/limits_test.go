@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"devtool/config"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunTool_RateLimit(t *testing.T) {
+	tool := config.ToolConfig{
+		Name:      "limited-tool",
+		Type:      "shell",
+		Command:   "true",
+		RateLimit: &config.RateLimitConfig{PerMinute: 2, Burst: 2},
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := ExecuteTool(tool, map[string]interface{}{}); err != nil {
+			t.Fatalf("Call %d failed: %v", i+1, err)
+		}
+	}
+
+	_, err := ExecuteTool(tool, map[string]interface{}{})
+	var rlErr *RateLimitError
+	if !errors.As(err, &rlErr) {
+		t.Fatalf("Expected RateLimitError, got %v", err)
+	}
+	if rlErr.RetryAfter < 20*time.Second || rlErr.RetryAfter > 31*time.Second {
+		t.Errorf("Expected retry after about 30s, got %s", rlErr.RetryAfter)
+	}
+}
+
+func TestRunTool_MaxConcurrent(t *testing.T) {
+	tool := config.ToolConfig{
+		Name:          "slow-tool",
+		Type:          "shell",
+		Command:       "sleep 0.3",
+		MaxConcurrent: 1,
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := ExecuteTool(tool, map[string]interface{}{})
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	_, err := ExecuteTool(tool, map[string]interface{}{})
+	var rlErr *RateLimitError
+	if !errors.As(err, &rlErr) {
+		t.Errorf("Expected RateLimitError for concurrent call, got %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("First call failed: %v", err)
+	}
+	if _, err := ExecuteTool(tool, map[string]interface{}{}); err != nil {
+		t.Errorf("Expected call to succeed after the first finished, got %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package tools
+
+import (
+	"devtool/config"
+	"devtool/metrics"
+)
+
+var (
+	executionsTotal = metrics.NewCounter("devtool_tool_executions_total",
+		"Tool executions by tool, type and outcome.", "tool", "type", "outcome")
+	executionSeconds = metrics.NewHistogram("devtool_tool_execution_duration_seconds",
+		"Time spent executing tools.", "tool", "type")
+	workflowStepSeconds = metrics.NewHistogram("devtool_workflow_step_duration_seconds",
+		"Time spent in each workflow step.", "workflow", "step")
+)
+
+func toolType(tool config.ToolConfig) string {
+	if tool.Type == "shell" || tool.Type == "mcp" {
+		return tool.Type
+	}
+	return "http"
+}
@@ -0,0 +1,262 @@
+package tools
+
+import (
+	"context"
+	"devtool/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const defaultMaxPages = 10
+
+// executePaginatedHTTPTool calls an HTTP tool repeatedly, following its
+// pagination settings, and returns the merged items as a JSON array. When
+// max_pages is reached before the last page, the items merged so far are
+// returned with an error, so partial results are never mistaken for all.
+// Items that don't fit in max_output_bytes are dropped whole, so the output
+// stays valid JSON, and reported as truncated with an error as well.
+func executePaginatedHTTPTool(ctx context.Context, tool config.ToolConfig, args map[string]interface{}) (string, bool, error) {
+	p := tool.Pagination
+
+	maxPages := p.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultMaxPages
+	}
+
+	param := p.Param
+	if param == "" {
+		switch p.Type {
+		case "cursor":
+			param = "cursor"
+		case "page":
+			param = "page"
+		}
+	}
+
+	page := p.StartPage
+	if page == 0 {
+		page = 1
+	}
+
+	pageArgs := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		pageArgs[k] = v
+	}
+
+	pageURL := tool.URL
+	items := []interface{}{}
+
+	for i := 1; i <= maxPages; i++ {
+		if p.Type == "page" {
+			pageArgs[param] = page
+		}
+
+		body, header, err := doHTTPRequest(ctx, tool, pageURL, pageArgs)
+		if err != nil {
+			return string(body), false, fmt.Errorf("page %d: %w", i, err)
+		}
+
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return string(body), false, fmt.Errorf("page %d is not valid JSON: %w", i, err)
+		}
+
+		pageItems, err := extractItems(doc, p.ItemsPath)
+		if err != nil {
+			return string(body), false, err
+		}
+		items = append(items, pageItems...)
+
+		// Without a spill file, pages that can't be kept are not fetched.
+		if tool.SpillDir == "" && tool.MaxOutputBytes > 0 {
+			if _, kept := fitItems(items, tool.MaxOutputBytes); kept < len(items) {
+				return mergedOutput(tool, items, nil)
+			}
+		}
+
+		switch p.Type {
+		case "page":
+			// A page shorter than a full one is the last, so the data
+			// ending at max_pages isn't reported as incomplete.
+			if len(pageItems) == 0 || len(pageItems) < p.PageSize {
+				return mergedOutput(tool, items, nil)
+			}
+			page++
+		case "cursor":
+			cursor, err := firstString(doc, p.NextPath)
+			if err != nil {
+				return string(body), false, err
+			}
+			if cursor == "" {
+				return mergedOutput(tool, items, nil)
+			}
+			pageArgs[param] = cursor
+		case "", "link":
+			var next string
+			if p.NextPath != "" {
+				next, err = firstString(doc, p.NextPath)
+				if err != nil {
+					return string(body), false, err
+				}
+			} else {
+				next = nextLink(header)
+			}
+			if next == "" {
+				return mergedOutput(tool, items, nil)
+			}
+
+			pageURL, err = resolveURL(pageURL, next)
+			if err != nil {
+				return string(body), false, err
+			}
+			// The next link already carries the query string.
+			if tool.Method == "GET" {
+				pageArgs = map[string]interface{}{}
+			}
+		default:
+			return "", false, fmt.Errorf("unknown pagination type '%s'", p.Type)
+		}
+	}
+
+	return mergedOutput(tool, items, fmt.Errorf("stopped after max_pages (%d) with more pages left; the results are incomplete", maxPages))
+}
+
+// mergedOutput returns the merged items as a JSON array, cut to the tool's
+// output limit. err, if any, is returned as is unless the output was
+// truncated, in which case the truncation is reported too.
+func mergedOutput(tool config.ToolConfig, items []interface{}, err error) (string, bool, error) {
+	if tool.MaxOutputBytes <= 0 {
+		output, merr := marshalItems(items)
+		if merr != nil {
+			return "", false, merr
+		}
+		return output, false, err
+	}
+
+	output, kept := fitItems(items, tool.MaxOutputBytes)
+	if kept == len(items) {
+		return output, false, err
+	}
+
+	msg := fmt.Sprintf("merged items exceed max_output_bytes (%d); kept %d of %d items", tool.MaxOutputBytes, kept, len(items))
+	if tool.SpillDir != "" {
+		full, merr := marshalItems(items)
+		if merr != nil {
+			return "", false, merr
+		}
+		if path, serr := spillOutput(tool, []byte(full)); serr != nil {
+			msg += fmt.Sprintf("; failed to save all items: %v", serr)
+		} else {
+			msg += "; all items saved to " + path
+		}
+	}
+	if err != nil {
+		msg += "; " + err.Error()
+	}
+	return output, true, errors.New(msg)
+}
+
+// fitItems marshals the longest run of leading items whose JSON array fits
+// in limit bytes, and returns it with the number of items kept.
+func fitItems(items []interface{}, limit int) (string, int) {
+	var b strings.Builder
+	b.WriteByte('[')
+	kept := 0
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil || b.Len()+len(data)+2 > limit {
+			break
+		}
+		if kept > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(data)
+		kept++
+	}
+	b.WriteByte(']')
+	return b.String(), kept
+}
+
+// extractItems returns the items of a page. A path matching a single array
+// yields its elements; otherwise every match is an item.
+func extractItems(doc interface{}, path string) ([]interface{}, error) {
+	matches, err := evalJSONPath(doc, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 1 {
+		if arr, ok := matches[0].([]interface{}); ok {
+			return arr, nil
+		}
+		if matches[0] == nil {
+			return nil, nil
+		}
+	}
+	return matches, nil
+}
+
+func firstString(doc interface{}, path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("pagination next_path is required")
+	}
+	matches, err := evalJSONPath(doc, path)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 || matches[0] == nil {
+		return "", nil
+	}
+	if s, ok := matches[0].(string); ok {
+		return s, nil
+	}
+	return fmt.Sprintf("%v", matches[0]), nil
+}
+
+// nextLink extracts the rel="next" target of an RFC 8288 Link header.
+func nextLink(header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, attr := range parts[1:] {
+				attr = strings.TrimSpace(attr)
+				if !strings.HasPrefix(attr, "rel=") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(attr[len("rel="):], "\"")) {
+					if rel == "next" {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func resolveURL(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL '%s': %w", base, err)
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid next link '%s': %w", ref, err)
+	}
+	return b.ResolveReference(r).String(), nil
+}
+
+func marshalItems(items []interface{}) (string, error) {
+	out, err := json.Marshal(items)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal merged pages: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,218 @@
+package tools
+
+import (
+	"devtool/config"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExecuteTool_HTTP_PaginationLink(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf("<%s/?page=2>; rel=\"next\"", ts.URL))
+			fmt.Fprint(w, `{"items": [{"id": 1}, {"id": 2}]}`)
+		case "2":
+			fmt.Fprint(w, `{"items": [{"id": 3}]}`)
+		default:
+			t.Errorf("Unexpected page %s", r.URL.Query().Get("page"))
+		}
+	}))
+	defer ts.Close()
+
+	tool := config.ToolConfig{
+		Name:       "list-pipelines",
+		URL:        ts.URL,
+		Method:     "GET",
+		Pagination: &config.PaginationConfig{ItemsPath: "$.items"},
+	}
+
+	output, err := ExecuteTool(tool, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("ExecuteTool failed: %v", err)
+	}
+
+	expected := `[{"id":1},{"id":2},{"id":3}]`
+	if output != expected {
+		t.Errorf("Expected output '%s', got '%s'", expected, output)
+	}
+}
+
+func TestExecuteTool_HTTP_PaginationCursor(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("owner") != "me" {
+			t.Errorf("Expected owner param on every page, got '%s'", r.URL.RawQuery)
+		}
+		switch r.URL.Query().Get("after") {
+		case "":
+			fmt.Fprint(w, `{"data": ["a", "b"], "meta": {"next": "c1"}}`)
+		case "c1":
+			fmt.Fprint(w, `{"data": ["c"], "meta": {"next": null}}`)
+		}
+	}))
+	defer ts.Close()
+
+	tool := config.ToolConfig{
+		Name:   "list-cursor",
+		URL:    ts.URL,
+		Method: "GET",
+		Pagination: &config.PaginationConfig{
+			Type:      "cursor",
+			ItemsPath: "$.data",
+			NextPath:  "$.meta.next",
+			Param:     "after",
+		},
+	}
+
+	output, err := ExecuteTool(tool, map[string]interface{}{"owner": "me"})
+	if err != nil {
+		t.Fatalf("ExecuteTool failed: %v", err)
+	}
+
+	expected := `["a","b","c"]`
+	if output != expected {
+		t.Errorf("Expected output '%s', got '%s'", expected, output)
+	}
+}
+
+func TestExecuteTool_HTTP_PaginationMaxPages(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `[%s]`, r.URL.Query().Get("page"))
+	}))
+	defer ts.Close()
+
+	tool := config.ToolConfig{
+		Name:   "list-pages",
+		URL:    ts.URL,
+		Method: "GET",
+		Pagination: &config.PaginationConfig{
+			Type:     "page",
+			MaxPages: 3,
+		},
+	}
+
+	output, err := ExecuteTool(tool, map[string]interface{}{})
+	if err == nil || !strings.Contains(err.Error(), "max_pages (3)") {
+		t.Errorf("Expected reaching max_pages to be reported, got %v", err)
+	}
+
+	if requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests)
+	}
+	expected := `[1,2,3]`
+	if output != expected {
+		t.Errorf("Expected output '%s', got '%s'", expected, output)
+	}
+}
+
+func TestEvalJSONPath(t *testing.T) {
+	doc := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "build"},
+			map[string]interface{}{"name": "deploy"},
+		},
+	}
+
+	matches, err := evalJSONPath(doc, "$.items[*].name")
+	if err != nil {
+		t.Fatalf("evalJSONPath failed: %v", err)
+	}
+	if len(matches) != 2 || matches[0] != "build" || matches[1] != "deploy" {
+		t.Errorf("Unexpected matches: %v", matches)
+	}
+
+	matches, err = evalJSONPath(doc, "$['items'][-1].name")
+	if err != nil {
+		t.Fatalf("evalJSONPath failed: %v", err)
+	}
+	if len(matches) != 1 || matches[0] != "deploy" {
+		t.Errorf("Unexpected matches: %v", matches)
+	}
+}
+
+func TestExecuteTool_HTTP_PaginationOutputLimit(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `["item-%s-a","item-%s-b"]`, r.URL.Query().Get("page"), r.URL.Query().Get("page"))
+	}))
+	defer ts.Close()
+
+	tool := config.ToolConfig{
+		Name:           "list-pages",
+		URL:            ts.URL,
+		Method:         "GET",
+		MaxOutputBytes: 40,
+		Pagination:     &config.PaginationConfig{Type: "page", MaxPages: 10},
+	}
+
+	output, err := ExecuteTool(tool, map[string]interface{}{})
+	if err == nil || !strings.Contains(err.Error(), "kept 3 of 4 items") {
+		t.Errorf("Expected the truncation to be reported, got %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected fetching to stop after 2 requests, got %d", requests)
+	}
+	expected := `["item-1-a","item-1-b","item-2-a"]`
+	if output != expected {
+		t.Errorf("Expected output '%s', got '%s'", expected, output)
+	}
+}
+
+func TestExecuteTool_HTTP_PaginationPageSize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "1":
+			fmt.Fprint(w, `[1,2]`)
+		case "2":
+			fmt.Fprint(w, `[3]`)
+		default:
+			t.Errorf("Unexpected page %s", r.URL.Query().Get("page"))
+		}
+	}))
+	defer ts.Close()
+
+	tool := config.ToolConfig{
+		Name:   "list-pages",
+		URL:    ts.URL,
+		Method: "GET",
+		Pagination: &config.PaginationConfig{
+			Type:     "page",
+			PageSize: 2,
+			MaxPages: 2,
+		},
+	}
+
+	output, err := ExecuteTool(tool, map[string]interface{}{})
+	if err != nil {
+		t.Errorf("Expected a short last page to end pagination, got %v", err)
+	}
+	if expected := `[1,2,3]`; output != expected {
+		t.Errorf("Expected output '%s', got '%s'", expected, output)
+	}
+}
+
+func TestExecuteTool_HTTP_PaginationPageTooLarge(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `["%s"]`, strings.Repeat("x", 1000))
+	}))
+	defer ts.Close()
+
+	tool := config.ToolConfig{
+		Name:           "list-pages",
+		URL:            ts.URL,
+		Method:         "GET",
+		MaxOutputBytes: 100,
+		Pagination:     &config.PaginationConfig{Type: "page"},
+	}
+
+	if _, err := ExecuteTool(tool, map[string]interface{}{}); err == nil || !strings.Contains(err.Error(), "exceeds max_output_bytes") {
+		t.Errorf("Expected an oversized page to be refused, got %v", err)
+	}
+}
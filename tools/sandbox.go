@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"devtool/config"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// SandboxHelperCommand is the hidden devtool subcommand that applies
+// resource limits inside the sandbox before executing the tool command.
+const SandboxHelperCommand = "__sandbox-exec"
+
+// sandboxArgv wraps argv so it runs inside a bubblewrap sandbox: read-only
+// root, private /dev, /proc and /tmp, an empty home directory with only the
+// workdir and explicit readable paths, explicit writable binds, no network
+// unless allowed, and resource limits applied by the devtool helper. The
+// sandbox has its own user namespace, so the process limit only counts its
+// own processes.
+func sandboxArgv(tool config.ToolConfig, argv []string, workdir string) ([]string, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("tool '%s' requires a sandbox, which is only supported on Linux", tool.Name)
+	}
+
+	sb := tool.Sandbox
+	wrapper := sb.Wrapper
+	if wrapper == "" {
+		wrapper = "bwrap"
+	}
+	wrapperPath, err := exec.LookPath(wrapper)
+	if err != nil {
+		// Fail closed: never fall back to running unconfined.
+		return nil, fmt.Errorf("sandbox for tool '%s' unavailable: %w", tool.Name, err)
+	}
+
+	out := []string{
+		wrapperPath,
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+		"--unshare-user",
+		"--unshare-pid",
+		"--unshare-ipc",
+		"--unshare-uts",
+		"--new-session",
+		"--die-with-parent",
+	}
+	if !sb.Network {
+		out = append(out, "--unshare-net")
+	}
+
+	// Hide the home directory, which holds credentials, except for what
+	// the tool needs to read. Writable binds come last so they win.
+	var self string
+	if sb.CPUSeconds > 0 || sb.MemoryMB > 0 || sb.MaxProcs > 0 {
+		if self, err = os.Executable(); err != nil {
+			return nil, fmt.Errorf("cannot apply sandbox limits: %w", err)
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "/" {
+		out = append(out, "--tmpfs", home)
+		readable := append([]string{}, sb.Readable...)
+		if workdir != "" {
+			readable = append(readable, workdir)
+		}
+		if self != "" {
+			readable = append(readable, self)
+		}
+		for _, r := range readable {
+			abs, err := filepath.Abs(r)
+			if err != nil {
+				return nil, fmt.Errorf("invalid readable path '%s': %w", r, err)
+			}
+			out = append(out, "--ro-bind", abs, abs)
+		}
+	}
+
+	for _, w := range sb.Writable {
+		abs, err := filepath.Abs(w)
+		if err != nil {
+			return nil, fmt.Errorf("invalid writable path '%s': %w", w, err)
+		}
+		out = append(out, "--bind", abs, abs)
+	}
+	if workdir != "" {
+		abs, err := filepath.Abs(workdir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workdir '%s': %w", workdir, err)
+		}
+		out = append(out, "--chdir", abs)
+	}
+	out = append(out, "--")
+
+	if self != "" {
+		out = append(out, self, SandboxHelperCommand,
+			"-cpu", strconv.Itoa(sb.CPUSeconds),
+			"-mem", strconv.Itoa(sb.MemoryMB),
+			"-nproc", strconv.Itoa(sb.MaxProcs),
+			"--")
+	}
+
+	return append(out, argv...), nil
+}
@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// RunSandboxHelper implements SandboxHelperCommand: it sets the requested
+// rlimits on itself and replaces its process image with the tool command.
+// It only returns on error.
+func RunSandboxHelper(args []string) error {
+	fs := flag.NewFlagSet(SandboxHelperCommand, flag.ContinueOnError)
+	cpu := fs.Uint64("cpu", 0, "CPU time limit in seconds")
+	mem := fs.Uint64("mem", 0, "Address space limit in MiB")
+	nproc := fs.Uint64("nproc", 0, "Process count limit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	argv := fs.Args()
+	if len(argv) == 0 {
+		return fmt.Errorf("%s: no command given", SandboxHelperCommand)
+	}
+
+	limits := []struct {
+		resource int
+		value    uint64
+	}{
+		{syscall.RLIMIT_CPU, *cpu},
+		{syscall.RLIMIT_AS, *mem * 1024 * 1024},
+		{unix.RLIMIT_NPROC, *nproc},
+	}
+	for _, l := range limits {
+		if l.value == 0 {
+			continue
+		}
+		if err := syscall.Setrlimit(l.resource, &syscall.Rlimit{Cur: l.value, Max: l.value}); err != nil {
+			return fmt.Errorf("setrlimit: %w", err)
+		}
+	}
+
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, argv, os.Environ())
+}
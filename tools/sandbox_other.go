@@ -0,0 +1,10 @@
+//go:build !linux
+
+package tools
+
+import "fmt"
+
+// RunSandboxHelper implements SandboxHelperCommand, which is Linux only.
+func RunSandboxHelper(args []string) error {
+	return fmt.Errorf("%s is only supported on Linux", SandboxHelperCommand)
+}
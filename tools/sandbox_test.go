@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"devtool/config"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSandboxArgv(t *testing.T) {
+	// Use a stand-in wrapper so the test doesn't depend on bubblewrap.
+	dir := t.TempDir()
+	wrapper := filepath.Join(dir, "fake-bwrap")
+	if err := os.WriteFile(wrapper, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("HOME", "/home/dev")
+
+	tool := config.ToolConfig{
+		Name: "sandboxed",
+		Sandbox: &config.SandboxConfig{
+			Wrapper:    wrapper,
+			Readable:   []string{"/home/dev/.gitconfig"},
+			Writable:   []string{"/var/cache/devtool"},
+			CPUSeconds: 5,
+			MaxProcs:   16,
+		},
+	}
+
+	argv, err := sandboxArgv(tool, []string{"sh", "-c", "make"}, "/src")
+	if err != nil {
+		t.Fatalf("sandboxArgv failed: %v", err)
+	}
+	cmdline := strings.Join(argv, " ")
+
+	for _, want := range []string{
+		wrapper + " --ro-bind / / ",
+		"--unshare-user",
+		"--unshare-net",
+		"--tmpfs /home/dev --ro-bind /home/dev/.gitconfig /home/dev/.gitconfig --ro-bind /src /src ",
+		"--bind /var/cache/devtool /var/cache/devtool",
+		"--chdir /src",
+		SandboxHelperCommand + " -cpu 5 -mem 0 -nproc 16 -- sh -c make",
+	} {
+		if !strings.Contains(cmdline, want) {
+			t.Errorf("Expected %q in sandbox command line: %s", want, cmdline)
+		}
+	}
+
+	tool.Sandbox.Network = true
+	argv, _ = sandboxArgv(tool, []string{"true"}, "")
+	if strings.Contains(strings.Join(argv, " "), "--unshare-net") {
+		t.Errorf("Expected network to be allowed: %v", argv)
+	}
+}
+
+func TestSandbox_MissingWrapperFailsClosed(t *testing.T) {
+	tool := config.ToolConfig{
+		Name:    "sandboxed",
+		Type:    "shell",
+		Command: "echo should-not-run",
+		Sandbox: &config.SandboxConfig{Wrapper: "devtool-no-such-wrapper"},
+	}
+
+	output, err := ExecuteTool(tool, map[string]interface{}{})
+	if err == nil {
+		t.Fatal("Expected error when the sandbox is unavailable, got nil")
+	}
+	if output != "" {
+		t.Errorf("Expected no output, got %q", output)
+	}
+}
+
+func TestSandbox_Bubblewrap(t *testing.T) {
+	if _, err := exec.LookPath("bwrap"); err != nil {
+		t.Skip("bwrap not installed")
+	}
+
+	tool := config.ToolConfig{
+		Name:    "sandboxed",
+		Type:    "shell",
+		Command: "touch /etc/devtool-sandbox-test",
+		Sandbox: &config.SandboxConfig{},
+	}
+
+	if _, err := ExecuteTool(tool, map[string]interface{}{}); err == nil {
+		t.Error("Expected write outside the writable paths to fail")
+	}
+}
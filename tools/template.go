@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"devtool/config"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var placeholderRe = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// renderTemplate replaces every {{name}} placeholder in s with the value
+// returned by lookup. Placeholders that lookup does not know are left as-is.
+// Substituted values are never expanded again.
+func renderTemplate(s string, lookup func(name string) (string, bool)) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	return placeholderRe.ReplaceAllStringFunc(s, func(m string) string {
+		name := placeholderRe.FindStringSubmatch(m)[1]
+		if val, ok := lookup(name); ok {
+			return val
+		}
+		return m
+	})
+}
+
+// expandParams replaces {{name}} placeholders in s with the matching tool
+// argument. Declared parameters that were not supplied expand to an empty
+// string; any other placeholder is left untouched.
+func expandParams(s string, args map[string]interface{}, params []config.Parameter) string {
+	return renderTemplate(s, func(name string) (string, bool) {
+		if v, ok := args[name]; ok {
+			return fmt.Sprintf("%v", v), true
+		}
+		for _, p := range params {
+			if p.Name == name {
+				return "", true
+			}
+		}
+		return "", false
+	})
+}
+
+// ExpandParams is expandParams for templates outside tool definitions, such
+// as prompt messages.
+func ExpandParams(s string, args map[string]interface{}, params []config.Parameter) string {
+	return expandParams(s, args, params)
+}
@@ -0,0 +1,555 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"devtool/config"
+	"devtool/logger"
+	"devtool/mcpclient"
+	"devtool/tracing"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClientInfo identifies devtool to upstream MCP servers.
+var ClientInfo = mcpclient.Implementation{Name: "devtool", Version: "dev"}
+
+const (
+	// upstreamConnectTimeout bounds connecting to and initializing an
+	// upstream server.
+	upstreamConnectTimeout = 30 * time.Second
+
+	// upstreamRetryDelay is how long a failed connection is remembered, so
+	// that listing tools doesn't wait on a dead server every time.
+	upstreamRetryDelay = 30 * time.Second
+)
+
+// upstream is the connection to one MCP server, shared by every tool
+// imported from it.
+type upstream struct {
+	name string // Name of the tool entry that first used it, for logs
+
+	mu       sync.Mutex
+	client   *mcpclient.Client
+	tools    []mcpclient.Tool // Cached until the server reports a change
+	changes  int              // Number of changes reported, to drop lists fetched before one
+	err      error            // Last connection failure
+	failedAt time.Time
+	dialing  chan struct{} // Closed when the connection attempt in progress ends
+	closed   bool          // Pruned; no new connections are kept
+}
+
+var (
+	upstreamsMu sync.Mutex
+	upstreams   = make(map[string]*upstream)
+
+	listenersMu  sync.Mutex
+	listeners    = make(map[int]func())
+	nextListener int
+)
+
+// OnUpstreamToolsChanged registers fn to be called when an upstream server
+// reports that its tools changed. It returns a function that unregisters fn.
+func OnUpstreamToolsChanged(fn func()) func() {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	id := nextListener
+	nextListener++
+	listeners[id] = fn
+	return func() {
+		listenersMu.Lock()
+		defer listenersMu.Unlock()
+		delete(listeners, id)
+	}
+}
+
+// upstreamKey identifies the server an MCP tool talks to, so that tools
+// imported from the same server share a connection and changed settings
+// get a new one.
+func upstreamKey(tool config.ToolConfig) string {
+	var token string
+	if tool.MCP != nil {
+		token = tool.MCP.Token
+	}
+	key, _ := json.Marshal([]interface{}{
+		tool.Command, tool.Args, tool.Interpreter, tool.WorkDir, tool.Env, tool.InheritEnv, tool.Sandbox,
+		tool.URL, tool.Headers, token,
+	})
+	return string(key)
+}
+
+func upstreamFor(tool config.ToolConfig) *upstream {
+	upstreamsMu.Lock()
+	defer upstreamsMu.Unlock()
+	key := upstreamKey(tool)
+	u, ok := upstreams[key]
+	if !ok {
+		u = &upstream{name: tool.Name}
+		upstreams[key] = u
+	}
+	return u
+}
+
+// CloseUpstreams disconnects from all servers, e.g. before exiting.
+func CloseUpstreams() {
+	PruneUpstreams(nil)
+}
+
+// PruneUpstreams disconnects from the servers that none of the tools use,
+// e.g. after a config reload.
+func PruneUpstreams(tools []config.ToolConfig) {
+	used := make(map[string]bool)
+	for _, t := range tools {
+		if t.Type == "mcp" {
+			used[upstreamKey(t)] = true
+		}
+	}
+
+	upstreamsMu.Lock()
+	var unused []*upstream
+	for key, u := range upstreams {
+		if !used[key] {
+			unused = append(unused, u)
+			delete(upstreams, key)
+		}
+	}
+	upstreamsMu.Unlock()
+
+	for _, u := range unused {
+		u.mu.Lock()
+		u.closed = true
+		if u.client != nil {
+			u.client.Close()
+		}
+		u.mu.Unlock()
+	}
+}
+
+// connect returns a working client, connecting first if needed. The
+// connection is made without holding u.mu, so that calls over an existing
+// connection don't wait for it; concurrent callers wait for the same
+// attempt.
+func (u *upstream) connect(tool config.ToolConfig) (*mcpclient.Client, error) {
+	u.mu.Lock()
+	for u.dialing != nil {
+		done := u.dialing
+		u.mu.Unlock()
+		<-done
+		u.mu.Lock()
+	}
+	if u.client != nil && u.client.Err() == nil {
+		defer u.mu.Unlock()
+		return u.client, nil
+	}
+	if u.err != nil && time.Since(u.failedAt) < upstreamRetryDelay {
+		defer u.mu.Unlock()
+		return nil, u.err
+	}
+	u.client, u.tools = nil, nil
+	done := make(chan struct{})
+	u.dialing = done
+	u.mu.Unlock()
+
+	client, err := dialUpstream(tool, mcpclient.Options{OnNotification: u.notified})
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.dialing = nil
+	close(done)
+	if err == nil && u.closed {
+		client.Close()
+		err = fmt.Errorf("the server is no longer configured")
+	}
+	if err != nil {
+		u.err, u.failedAt = err, time.Now()
+		return nil, err
+	}
+	u.client, u.err = client, nil
+	logger.Info("Connected to MCP server %s %s for %s (protocol %s)", client.ServerInfo.Name, client.ServerInfo.Version, u.name, client.ProtocolVersion)
+	return client, nil
+}
+
+// notified handles notifications from the server.
+func (u *upstream) notified(method string, params json.RawMessage) {
+	if method != "notifications/tools/list_changed" {
+		return
+	}
+	go func() {
+		u.mu.Lock()
+		u.tools = nil
+		u.changes++
+		u.mu.Unlock()
+
+		listenersMu.Lock()
+		fns := make([]func(), 0, len(listeners))
+		for _, fn := range listeners {
+			fns = append(fns, fn)
+		}
+		listenersMu.Unlock()
+		for _, fn := range fns {
+			fn()
+		}
+	}()
+}
+
+// listTools returns the server's tools, listing them once per connection
+// and change.
+func (u *upstream) listTools(ctx context.Context, tool config.ToolConfig) ([]mcpclient.Tool, error) {
+	client, err := u.connect(tool)
+	if err != nil {
+		return nil, err
+	}
+
+	u.mu.Lock()
+	cached, changes := u.tools, u.changes
+	u.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	list, err := client.ListTools(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("tools/list failed: %w", err)
+	}
+	u.mu.Lock()
+	if u.client == client && u.changes == changes {
+		u.tools = list
+	}
+	u.mu.Unlock()
+	return list, nil
+}
+
+func (u *upstream) callTool(ctx context.Context, tool config.ToolConfig, params mcpclient.CallToolParams) (*mcpclient.CallToolResult, error) {
+	client, err := u.connect(tool)
+	if err != nil {
+		return nil, err
+	}
+	return client.CallTool(ctx, params)
+}
+
+// dialUpstream connects to the server of an MCP tool and initializes the
+// session.
+func dialUpstream(tool config.ToolConfig, opts mcpclient.Options) (*mcpclient.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamConnectTimeout)
+	defer cancel()
+
+	var token string
+	if tool.MCP != nil {
+		token = os.ExpandEnv(tool.MCP.Token)
+	}
+
+	var client *mcpclient.Client
+	if tool.URL == "" {
+		cmd, err := buildShellCommand(context.Background(), tool, nil)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = shellEnv(tool, nil)
+		cmd.Dir = tool.WorkDir
+		cmd.Stderr = &logWriter{log: logger.With("upstream", tool.Name)}
+		if client, err = mcpclient.Start(cmd, opts); err != nil {
+			return nil, fmt.Errorf("failed to start MCP server: %w", err)
+		}
+	} else {
+		addr, err := url.Parse(tool.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url: %w", err)
+		}
+		switch addr.Scheme {
+		case "http", "https":
+			header := make(http.Header)
+			for k, v := range tool.Headers {
+				header.Set(k, os.ExpandEnv(v))
+			}
+			if token != "" {
+				header.Set("Authorization", "Bearer "+token)
+			}
+			client = mcpclient.NewHTTP(tool.URL, header, opts)
+		case "tcp", "tls":
+			var tlsCfg *tls.Config
+			if addr.Scheme == "tls" {
+				tlsCfg = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
+			if client, err = mcpclient.Dial(ctx, addr.Host, tlsCfg, opts); err != nil {
+				return nil, fmt.Errorf("failed to connect to MCP server: %w", err)
+			}
+			if token != "" {
+				if err := client.Authenticate(ctx, token); err != nil {
+					client.Close()
+					return nil, fmt.Errorf("authentication failed: %w", err)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unsupported url scheme '%s' (expected http, https, tcp or tls)", addr.Scheme)
+		}
+	}
+
+	if err := client.Initialize(ctx, ClientInfo); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// logWriter logs every line written to it, e.g. the stderr of a server.
+type logWriter struct {
+	log *logger.Logger
+	buf []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimSpace(string(w.buf[:i])); line != "" {
+			w.log.Info("%s", line)
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// ImportTools returns tools with every MCP entry replaced by the tools it
+// imports from its server. Imported tools keep the entry's settings, such
+// as tags, confirm and rate limits. Servers that can't be reached are left
+// out and reported in the error, which doesn't stop the others from being
+// imported.
+func ImportTools(ctx context.Context, tools []config.ToolConfig) ([]config.ToolConfig, error) {
+	taken := make(map[string]bool)
+	for _, t := range tools {
+		if t.Type != "mcp" {
+			taken[t.Name] = true
+		}
+	}
+
+	var list []config.ToolConfig
+	var errs []error
+	for _, t := range tools {
+		if t.Type != "mcp" {
+			list = append(list, t)
+			continue
+		}
+		settings := mcpSettings(t)
+		upstreamTools, err := upstreamFor(t).listTools(ctx, t)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tool '%s': %w", t.Name, err))
+			continue
+		}
+
+		found := false
+		for _, ut := range upstreamTools {
+			if !importsTool(settings, ut.Name) {
+				continue
+			}
+			found = true
+			imported := importTool(t, settings, ut)
+			if taken[imported.Name] {
+				errs = append(errs, fmt.Errorf("tool '%s': '%s' is already defined; add a prefix", t.Name, imported.Name))
+				continue
+			}
+			taken[imported.Name] = true
+			list = append(list, imported)
+		}
+		if !found && settings.Tool != "" {
+			errs = append(errs, fmt.Errorf("tool '%s': the server has no tool '%s'", t.Name, settings.Tool))
+		}
+	}
+	return list, errors.Join(errs...)
+}
+
+func mcpSettings(tool config.ToolConfig) config.MCPConfig {
+	if tool.MCP == nil {
+		return config.MCPConfig{}
+	}
+	return *tool.MCP
+}
+
+// importsTool reports whether an upstream tool is selected by the settings.
+func importsTool(settings config.MCPConfig, name string) bool {
+	if settings.Tool != "" {
+		return name == settings.Tool
+	}
+	match := func(patterns []string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(settings.Include) == 0 || match(settings.Include)) && !match(settings.Exclude)
+}
+
+// importTool derives the config of an upstream tool from its entry. What
+// the entry sets explicitly wins over what the server reports.
+func importTool(entry config.ToolConfig, settings config.MCPConfig, ut mcpclient.Tool) config.ToolConfig {
+	t := entry
+	if settings.Tool == "" {
+		t.Name = settings.Prefix + ut.Name
+		// The entry's description is about the server
+		t.Description = ut.Description
+	} else if t.Description == "" {
+		t.Description = ut.Description
+	}
+	settings.Tool = ut.Name
+	t.MCP = &settings
+
+	if len(t.Parameters) == 0 {
+		t.Parameters = schemaParameters(ut.InputSchema)
+		t.InputSchema = ut.InputSchema
+	}
+
+	// The server's hints are only trusted when the entry opts in by setting
+	// annotations; otherwise imported tools get the defaults of MCP tools,
+	// destructive and open-world.
+	annotations := &config.Annotations{Title: ut.Title}
+	if a := ut.Annotations; a != nil && a.Title != "" {
+		annotations.Title = a.Title
+	}
+	if a := ut.Annotations; a != nil && entry.Annotations != nil {
+		annotations.ReadOnly, annotations.Destructive = a.ReadOnlyHint, a.DestructiveHint
+		annotations.Idempotent, annotations.OpenWorld = a.IdempotentHint, a.OpenWorldHint
+	}
+	if a := entry.Annotations; a != nil {
+		if a.Title != "" {
+			annotations.Title = a.Title
+		}
+		if a.ReadOnly != nil {
+			annotations.ReadOnly = a.ReadOnly
+		}
+		if a.Destructive != nil {
+			annotations.Destructive = a.Destructive
+		}
+		if a.Idempotent != nil {
+			annotations.Idempotent = a.Idempotent
+		}
+		if a.OpenWorld != nil {
+			annotations.OpenWorld = a.OpenWorld
+		}
+	}
+	t.Annotations = annotations
+	return t
+}
+
+// schemaParameters describes the top-level properties of a JSON Schema as
+// parameters, for completion and the wizard.
+func schemaParameters(schema map[string]interface{}) []config.Parameter {
+	props, _ := schema["properties"].(map[string]interface{})
+	required := make(map[string]bool)
+	if list, ok := schema["required"].([]interface{}); ok {
+		for _, name := range list {
+			if s, ok := name.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var params []config.Parameter
+	for _, name := range names {
+		p := config.Parameter{Name: name, Required: required[name]}
+		if prop, ok := props[name].(map[string]interface{}); ok {
+			p.Description, _ = prop["description"].(string)
+			switch typ := prop["type"].(type) {
+			case string:
+				p.Type = typ
+			case []interface{}:
+				// e.g. ["string", "null"]
+				if len(typ) > 0 {
+					p.Type, _ = typ[0].(string)
+				}
+			}
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+// executeMCPTool calls an imported tool on its server.
+func executeMCPTool(ctx context.Context, tool config.ToolConfig, args map[string]interface{}) (*Result, error) {
+	name := mcpSettings(tool).Tool
+	if name == "" {
+		return &Result{}, fmt.Errorf("tool '%s' is an MCP server; call one of its tools instead", tool.Name)
+	}
+
+	ctx, span := tracing.Start(ctx, "MCP tools/call "+name, tracing.KindClient)
+	defer span.Finish()
+	span.SetAttribute("mcp.tool", name)
+
+	params := mcpclient.CallToolParams{Name: name, Arguments: args}
+	if tp := tracing.Traceparent(ctx); tp != "" {
+		params.Meta = map[string]interface{}{"traceparent": tp}
+	}
+	result, err := upstreamFor(tool).callTool(ctx, tool, params)
+	if err != nil {
+		span.RecordError(err)
+		return &Result{}, fmt.Errorf("call to MCP server failed: %w", err)
+	}
+
+	data, mimeType := upstreamOutput(result.Content)
+	out := newCapture(tool, true)
+	out.Write(data)
+	res := &Result{Output: out.String(), MimeType: mimeType, Truncated: out.truncated(), Structured: result.StructuredContent}
+	if result.IsError {
+		return res, fmt.Errorf("tool '%s' reported an error", name)
+	}
+	return res, nil
+}
+
+// upstreamOutput turns the content of an upstream result into tool output.
+// A single image, audio or resource block keeps its data and MIME type;
+// otherwise the text blocks are joined and other blocks are summarized.
+func upstreamOutput(content []mcpclient.Content) ([]byte, string) {
+	if len(content) == 1 {
+		c := content[0]
+		switch c.Type {
+		case "image", "audio":
+			if data, err := base64.StdEncoding.DecodeString(c.Data); err == nil {
+				return data, c.MimeType
+			}
+		case "resource":
+			if r := c.Resource; r != nil {
+				if r.Blob == "" {
+					return []byte(r.Text), r.MimeType
+				}
+				if data, err := base64.StdEncoding.DecodeString(r.Blob); err == nil {
+					return data, r.MimeType
+				}
+			}
+		}
+	}
+
+	var parts []string
+	for _, c := range content {
+		switch {
+		case c.Type == "text":
+			parts = append(parts, c.Text)
+		case c.Type == "resource" && c.Resource != nil && c.Resource.Blob == "":
+			parts = append(parts, c.Resource.Text)
+		case c.Type == "resource_link":
+			parts = append(parts, c.URI)
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content omitted]", c.Type))
+		}
+	}
+	return []byte(strings.Join(parts, "\n")), ""
+}
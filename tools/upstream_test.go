@@ -0,0 +1,219 @@
+package tools
+
+import (
+	"context"
+	"devtool/config"
+	"devtool/mcpclient"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeUpstream serves an MCP server over Streamable HTTP with the tools
+// search, chart, hidden and broken. It counts the tools/list requests.
+func fakeUpstream(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+	readOnly := true
+	list := []mcpclient.Tool{
+		{
+			Name:        "search",
+			Description: "Search the index",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"q":     map[string]interface{}{"type": "string", "description": "Query"},
+					"limit": map[string]interface{}{"type": []interface{}{"integer", "null"}},
+				},
+				"required": []interface{}{"q"},
+			},
+			Annotations: &mcpclient.ToolAnnotations{ReadOnlyHint: &readOnly},
+		},
+		{Name: "chart"},
+		{Name: "hidden"},
+		{Name: "broken"},
+	}
+
+	var lists int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				Name      string                 `json:"name"`
+				Arguments map[string]interface{} `json:"arguments"`
+			} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		var result interface{}
+		switch req.Method {
+		case "initialize":
+			result = map[string]interface{}{"protocolVersion": mcpclient.ProtocolVersion, "serverInfo": map[string]string{"name": "fake"}}
+		case "tools/list":
+			atomic.AddInt32(&lists, 1)
+			result = map[string]interface{}{"tools": list}
+		case "tools/call":
+			switch req.Params.Name {
+			case "search":
+				result = mcpclient.CallToolResult{
+					Content:           []mcpclient.Content{{Type: "text", Text: "found " + req.Params.Arguments["q"].(string)}},
+					StructuredContent: map[string]interface{}{"hits": 1},
+				}
+			case "chart":
+				result = mcpclient.CallToolResult{Content: []mcpclient.Content{{Type: "image", Data: base64.StdEncoding.EncodeToString([]byte("PNG")), MimeType: "image/png"}}}
+			default:
+				result = mcpclient.CallToolResult{Content: []mcpclient.Content{{Type: "text", Text: "boom"}}, IsError: true}
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &lists
+}
+
+func TestImportTools(t *testing.T) {
+	srv, lists := fakeUpstream(t)
+	entries := []config.ToolConfig{
+		{Name: "local", Type: "shell", Command: "true"},
+		{
+			Name: "index", Type: "mcp", URL: srv.URL, Tags: []string{"search"}, Confirm: true,
+			MCP: &config.MCPConfig{Prefix: "idx_", Exclude: []string{"hidden"}},
+		},
+		{
+			Name: "find", Type: "mcp", URL: srv.URL, Description: "Find documents",
+			MCP: &config.MCPConfig{Tool: "search"},
+		},
+		{
+			Name: "lookup", Type: "mcp", URL: srv.URL, Annotations: &config.Annotations{Title: "Lookup"},
+			MCP: &config.MCPConfig{Tool: "search"},
+		},
+		{Name: "down", Type: "mcp", URL: "http://127.0.0.1:1/mcp"},
+	}
+
+	imported, err := ImportTools(context.Background(), entries)
+	if err == nil || !strings.Contains(err.Error(), "tool 'down'") {
+		t.Errorf("Expected the unreachable server to be reported, got %v", err)
+	}
+	var names []string
+	byName := map[string]config.ToolConfig{}
+	for _, tool := range imported {
+		names = append(names, tool.Name)
+		byName[tool.Name] = tool
+	}
+	if strings.Join(names, ",") != "local,idx_search,idx_chart,idx_broken,find,lookup" {
+		t.Fatalf("Unexpected tools %v", names)
+	}
+
+	search := byName["idx_search"]
+	if search.Description != "Search the index" || !search.Confirm || search.Tags[0] != "search" || search.MCP.Tool != "search" {
+		t.Errorf("Expected the upstream description and the entry's settings, got %+v", search)
+	}
+	expected := []config.Parameter{{Name: "limit", Type: "integer"}, {Name: "q", Type: "string", Description: "Query", Required: true}}
+	if !reflect.DeepEqual(search.Parameters, expected) || search.InputSchema == nil {
+		t.Errorf("Expected parameters from the schema, got %+v", search.Parameters)
+	}
+	// The server's hints are only used when the entry sets annotations
+	if h := search.Hints(); h.ReadOnly || !h.Destructive || !h.OpenWorld {
+		t.Errorf("Expected upstream annotations to be ignored by default, got %+v", h)
+	}
+	if h := byName["lookup"].Hints(); !h.ReadOnly || h.Destructive || h.Title != "Lookup" {
+		t.Errorf("Expected upstream annotations with the entry's overrides, got %+v", h)
+	}
+	if h := byName["idx_chart"].Hints(); h.ReadOnly || !h.Destructive || !h.OpenWorld {
+		t.Errorf("Expected unannotated tools to be destructive, got %+v", h)
+	}
+	if find := byName["find"]; find.Description != "Find documents" || find.MCP.Tool != "search" {
+		t.Errorf("Expected the single tool entry to keep its name and description, got %+v", find)
+	}
+
+	// The list is cached per server
+	ImportTools(context.Background(), entries)
+	if n := atomic.LoadInt32(lists); n != 1 {
+		t.Errorf("Expected the tools to be listed once, got %d", n)
+	}
+}
+
+func TestRunTool_MCP(t *testing.T) {
+	srv, _ := fakeUpstream(t)
+	imported, _ := ImportTools(context.Background(), []config.ToolConfig{
+		{Name: "index", Type: "mcp", URL: srv.URL, MaxOutputBytes: 1024},
+	})
+	tools := map[string]config.ToolConfig{}
+	for _, tool := range imported {
+		tools[tool.Name] = tool
+	}
+
+	res, err := RunTool(context.Background(), tools["search"], map[string]interface{}{"q": "go"})
+	if err != nil || res.Output != "found go" || !reflect.DeepEqual(res.Structured, map[string]interface{}{"hits": float64(1)}) {
+		t.Errorf("Unexpected search result %+v, %v", res, err)
+	}
+	res, err = RunTool(context.Background(), tools["chart"], nil)
+	if err != nil || res.Output != "PNG" || res.MimeType != "image/png" {
+		t.Errorf("Expected the image data, got %+v, %v", res, err)
+	}
+	res, err = RunTool(context.Background(), tools["broken"], nil)
+	if err == nil || res.Output != "boom" {
+		t.Errorf("Expected the tool error with its output, got %+v, %v", res, err)
+	}
+	if _, err := RunTool(context.Background(), config.ToolConfig{Name: "index", Type: "mcp", URL: srv.URL}, nil); err == nil {
+		t.Error("Expected calling the server entry itself to fail")
+	}
+
+	// Imported tools work as workflow steps
+	wf := config.WorkflowConfig{
+		Name:   "lookup",
+		Steps:  []config.StepConfig{{Name: "s", Tool: "search", Args: map[string]interface{}{"q": "{{input.term}}"}}},
+		Output: "{{s}}!",
+	}
+	output, err := RunWorkflow(context.Background(), wf, imported, map[string]interface{}{"term": "mcp"})
+	if err != nil || output != "found mcp!" {
+		t.Errorf("Unexpected workflow output %q, %v", output, err)
+	}
+}
+
+func TestUpstreamOutput(t *testing.T) {
+	tests := []struct {
+		content  []mcpclient.Content
+		output   string
+		mimeType string
+	}{
+		{[]mcpclient.Content{{Type: "text", Text: "a"}, {Type: "text", Text: "b"}}, "a\nb", ""},
+		{[]mcpclient.Content{{Type: "resource", Resource: &mcpclient.ResourceContents{URI: "file:///a.csv", MimeType: "text/csv", Text: "x,y"}}}, "x,y", "text/csv"},
+		{[]mcpclient.Content{{Type: "resource", Resource: &mcpclient.ResourceContents{URI: "file:///a.pdf", MimeType: "application/pdf", Blob: "JVBERg=="}}}, "%PDF", "application/pdf"},
+		{[]mcpclient.Content{{Type: "text", Text: "see"}, {Type: "image", Data: "UE5H", MimeType: "image/png"}, {Type: "resource_link", URI: "file:///b"}}, "see\n[image content omitted]\nfile:///b", ""},
+	}
+	for _, tt := range tests {
+		output, mimeType := upstreamOutput(tt.content)
+		if string(output) != tt.output || mimeType != tt.mimeType {
+			t.Errorf("%+v: expected %q (%s), got %q (%s)", tt.content, tt.output, tt.mimeType, output, mimeType)
+		}
+	}
+}
+
+func TestOnUpstreamToolsChanged(t *testing.T) {
+	kept := make(chan struct{}, 1)
+	var removed int32
+	defer OnUpstreamToolsChanged(func() { kept <- struct{}{} })()
+	unregister := OnUpstreamToolsChanged(func() { atomic.AddInt32(&removed, 1) })
+	unregister()
+
+	(&upstream{name: "fake"}).notified("notifications/tools/list_changed", nil)
+	select {
+	case <-kept:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the registered listener to be called")
+	}
+	if atomic.LoadInt32(&removed) != 0 {
+		t.Error("Expected the unregistered listener not to be called")
+	}
+}
@@ -1,20 +1,41 @@
 package tools
 
 import (
+	"context"
 	"devtool/config"
+	"devtool/tracing"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // ExecuteWorkflow executes a defined workflow.
 func ExecuteWorkflow(wf config.WorkflowConfig, tools []config.ToolConfig, globalArgs map[string]interface{}) (string, error) {
-	// Store outputs from each step
-	outputs := make(map[string]string)
-	
-	// Add global args to outputs for substitution (e.g. {{args.ip}})
-	// We flat map them for now? Or keep under "args"?
-	// Let's use "input" namespace.
-	
+	return RunWorkflow(context.Background(), wf, tools, globalArgs)
+}
+
+// RunWorkflow executes a defined workflow, passing ctx to every step. Its
+// output is capped like tool output, at config.DefaultMaxOutputBytes unless
+// the workflow sets a limit.
+func RunWorkflow(ctx context.Context, wf config.WorkflowConfig, tools []config.ToolConfig, globalArgs map[string]interface{}) (string, error) {
+	// Template variables: {{input.argName}} for global args and, for every
+	// finished step, {{step}} / {{step.stdout}}, {{step.stderr}} and
+	// {{step.exit_code}}.
+	ctx, span := tracing.Start(ctx, "workflow "+wf.Name, tracing.KindInternal)
+	span.SetAttribute("workflow.name", wf.Name)
+	defer span.Finish()
+
+	vars := make(map[string]string)
+	for argKey, argVal := range globalArgs {
+		vars["input."+argKey] = fmt.Sprintf("%v", argVal)
+	}
+	lookup := func(name string) (string, bool) {
+		val, ok := vars[name]
+		return val, ok
+	}
+
+	var completed []string
 	for _, step := range wf.Steps {
 		// Find the tool
 		var tool *config.ToolConfig
@@ -28,71 +49,56 @@ func ExecuteWorkflow(wf config.WorkflowConfig, tools []config.ToolConfig, global
 			return "", fmt.Errorf("tool '%s' not found for step '%s'", step.Tool, step.Name)
 		}
 
-		// Prepare arguments
+		// Prepare arguments, resolving templates in string values
 		stepArgs := make(map[string]interface{})
 		for k, v := range step.Args {
-			// Resolve templates in values
-			valStr, ok := v.(string)
-			if ok {
-				// Simple substitution: {{stepName}} with its output
-				// and {{input.argName}} with global args
-				
-				// 1. Inputs
-				for argKey, argVal := range globalArgs {
-					placeholder := fmt.Sprintf("{{input.%s}}", argKey)
-					if strings.Contains(valStr, placeholder) {
-						valStr = strings.ReplaceAll(valStr, placeholder, fmt.Sprintf("%v", argVal))
-					}
-				}
-
-				// 2. Previous step outputs
-				for stepName, stepOutput := range outputs {
-					placeholder := fmt.Sprintf("{{%s}}", stepName)
-					if strings.Contains(valStr, placeholder) {
-						valStr = strings.ReplaceAll(valStr, placeholder, stepOutput)
-					}
-				}
-				stepArgs[k] = valStr
+			if valStr, ok := v.(string); ok {
+				stepArgs[k] = renderTemplate(valStr, lookup)
 			} else {
 				stepArgs[k] = v
 			}
 		}
 
 		// Execute tool
-		out, err := ExecuteTool(*tool, stepArgs)
+		stepCtx, stepSpan := tracing.Start(ctx, "step "+step.Name, tracing.KindInternal)
+		stepSpan.SetAttribute("workflow.step", step.Name)
+		stepSpan.SetAttribute("tool.name", step.Tool)
+		start := time.Now()
+		res, err := RunTool(stepCtx, *tool, stepArgs)
+		workflowStepSeconds.Observe(time.Since(start).Seconds(), wf.Name, step.Name)
+		stepSpan.RecordError(err)
+		stepSpan.Finish()
 		if err != nil {
-			return "", fmt.Errorf("step '%s' failed: %w. Output: %s", step.Name, err, out)
+			span.RecordError(err)
+			if res.Stderr != "" {
+				return "", fmt.Errorf("step '%s' failed: %w. Output: %s. Stderr: %s", step.Name, err, res.Output, res.Stderr)
+			}
+			return "", fmt.Errorf("step '%s' failed: %w. Output: %s", step.Name, err, res.Output)
 		}
-		
+
 		// Trim whitespace for cleaner substitution
-		outputs[step.Name] = strings.TrimSpace(out)
+		out := strings.TrimSpace(res.Output)
+		vars[step.Name] = out
+		vars[step.Name+".stdout"] = out
+		vars[step.Name+".stderr"] = strings.TrimSpace(res.Stderr)
+		vars[step.Name+".exit_code"] = strconv.Itoa(res.ExitCode)
+		completed = append(completed, step.Name)
 	}
 
 	// Format final output
-	result := wf.Output
-	if result == "" {
-		// Default to dumping all steps
-		var builder strings.Builder
-		for k, v := range outputs {
-			builder.WriteString(fmt.Sprintf("%s: %s\n", k, v))
-		}
-		return builder.String(), nil
-	}
-
-	// Replace placeholders in output template
-	for stepName, stepOutput := range outputs {
-		placeholder := fmt.Sprintf("{{%s}}", stepName)
-		if strings.Contains(result, placeholder) {
-			result = strings.ReplaceAll(result, placeholder, stepOutput)
-		}
+	limit := wf.MaxOutputBytes
+	if limit == 0 {
+		limit = config.DefaultMaxOutputBytes
 	}
-	
-	for argKey, argVal := range globalArgs {
-		placeholder := fmt.Sprintf("{{input.%s}}", argKey)
-		if strings.Contains(result, placeholder) {
-			result = strings.ReplaceAll(result, placeholder, fmt.Sprintf("%v", argVal))
+	output := newCapture(config.ToolConfig{Name: wf.Name, MaxOutputBytes: limit}, false)
+	if wf.Output == "" {
+		// Default to dumping all steps
+		for _, name := range completed {
+			fmt.Fprintf(output, "%s: %s\n", name, vars[name])
 		}
+		return output.String(), nil
 	}
 
-	return result, nil
+	output.Write([]byte(renderTemplate(wf.Output, lookup)))
+	return output.String(), nil
 }
@@ -13,11 +13,17 @@ func TestExecuteWorkflow(t *testing.T) {
 			Name:    "echo-tool",
 			Type:    "shell",
 			Command: "printf '%s' \"$TEXT\"",
+			Parameters: []config.Parameter{
+				{Name: "text", Type: "string"},
+			},
 		},
 		{
 			Name:    "reverse-tool",
 			Type:    "shell",
 			Command: "printf '%s' \"$INPUT\" | rev",
+			Parameters: []config.Parameter{
+				{Name: "input", Type: "string"},
+			},
 		},
 	}
 
@@ -79,6 +85,26 @@ func TestExecuteWorkflow_MissingTool(t *testing.T) {
 	}
 }
 
+func TestExecuteWorkflow_OutputLimit(t *testing.T) {
+	tools := []config.ToolConfig{
+		{Name: "long", Type: "shell", Command: "printf 'abcdefghijklmnopqrstuvwxyz'"},
+	}
+	wf := config.WorkflowConfig{
+		Name:           "long-wf",
+		Steps:          []config.StepConfig{{Name: "a", Tool: "long"}, {Name: "b", Tool: "long"}},
+		MaxOutputBytes: 10,
+	}
+
+	output, err := ExecuteWorkflow(wf, tools, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("ExecuteWorkflow failed: %v", err)
+	}
+	expected := "a: ab\n... [truncated 50 of 60 bytes] ...\nwxyz\n"
+	if output != expected {
+		t.Errorf("Expected output %q, got %q", expected, output)
+	}
+}
+
 func TestExecuteWorkflow_StepFailure(t *testing.T) {
 	tools := []config.ToolConfig{
 		{
@@ -102,3 +128,31 @@ func TestExecuteWorkflow_StepFailure(t *testing.T) {
 		t.Error("Expected error for failing step, got nil")
 	}
 }
+
+func TestExecuteWorkflow_StepStreams(t *testing.T) {
+	tools := []config.ToolConfig{
+		{
+			Name:             "check-tool",
+			Type:             "shell",
+			Command:          "echo ok; echo warning >&2; exit 3",
+			SuccessExitCodes: []int{3},
+		},
+	}
+	wf := config.WorkflowConfig{
+		Name: "streams-wf",
+		Steps: []config.StepConfig{
+			{Name: "check", Tool: "check-tool"},
+		},
+		Output: "{{check.stdout}}|{{check.stderr}}|{{check.exit_code}}",
+	}
+
+	output, err := ExecuteWorkflow(wf, tools, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("ExecuteWorkflow failed: %v", err)
+	}
+
+	expected := "ok|warning|3"
+	if output != expected {
+		t.Errorf("Expected output '%s', got '%s'", expected, output)
+	}
+}
@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"context"
+	"devtool/config"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// shutdownTimeout bounds how long flushing spans may delay shutdown.
+const shutdownTimeout = 10 * time.Second
+
+// Setup enables tracing as described by cfg. It returns a function that
+// flushes outstanding spans and disables tracing again. With no exporter
+// configured, tracing stays disabled and Start returns nil spans.
+func Setup(cfg config.TracingConfig) (shutdown func(), err error) {
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case "", "none":
+		return func() {}, nil
+	case "otlp":
+		headers := make(map[string]string, len(cfg.Headers))
+		for k, v := range cfg.Headers {
+			headers[k] = os.ExpandEnv(v)
+		}
+		exporter, err = otlptracehttp.New(context.Background(),
+			otlptracehttp.WithEndpointURL(otlpURL(cfg.Endpoint)),
+			otlptracehttp.WithHeaders(headers))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+	case "stdout":
+		exporter, err = NewWriterExporter(os.Stdout)
+	case "stderr":
+		exporter, err = NewWriterExporter(os.Stderr)
+	case "file":
+		f, ferr := os.OpenFile(cfg.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if ferr != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", ferr)
+		}
+		exporter, err = NewWriterExporter(f)
+		if err == nil {
+			exporter = &closingExporter{SpanExporter: exporter, closer: f}
+		}
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter '%s' (expected otlp, file, stdout or stderr)", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return SetExporter(exporter, cfg.ServiceName), nil
+}
+
+// SetExporter enables tracing with a custom exporter. Spans are exported
+// in batches in the background.
+func SetExporter(exporter sdktrace.SpanExporter, service string) (shutdown func()) {
+	if service == "" {
+		service = "devtool"
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	)
+
+	providerMu.Lock()
+	provider = tp
+	providerMu.Unlock()
+
+	return func() {
+		providerMu.Lock()
+		if provider == tp {
+			provider = nil
+		}
+		providerMu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "tracing: shutdown failed: %v\n", err)
+		}
+	}
+}
+
+// NewWriterExporter returns an exporter that writes each span as one JSON
+// line, for offline use.
+func NewWriterExporter(w io.Writer) (sdktrace.SpanExporter, error) {
+	return stdouttrace.New(stdouttrace.WithWriter(w))
+}
+
+// otlpURL returns the traces URL of an OTLP/HTTP collector base URL.
+func otlpURL(endpoint string) string {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if strings.HasSuffix(endpoint, "/v1/traces") {
+		return endpoint
+	}
+	return endpoint + "/v1/traces"
+}
+
+// closingExporter closes the exporter's output file on shutdown.
+type closingExporter struct {
+	sdktrace.SpanExporter
+	closer io.Closer
+}
+
+func (e *closingExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if cerr := e.closer.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
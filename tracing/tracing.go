@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SpanKind follows the OpenTelemetry span kinds.
+type SpanKind = trace.SpanKind
+
+const (
+	KindInternal = trace.SpanKindInternal
+	KindServer   = trace.SpanKindServer
+	KindClient   = trace.SpanKindClient
+)
+
+var (
+	providerMu sync.RWMutex
+	provider   *sdktrace.TracerProvider
+)
+
+func tracer() trace.Tracer {
+	providerMu.RLock()
+	defer providerMu.RUnlock()
+	if provider == nil {
+		return nil
+	}
+	return provider.Tracer("devtool")
+}
+
+// propagator reads and writes W3C traceparent values.
+var propagator = propagation.TraceContext{}
+
+// ParseTraceparent parses a W3C traceparent header value.
+func ParseTraceparent(s string) (trace.SpanContext, error) {
+	ctx := propagator.Extract(context.Background(), propagation.MapCarrier{"traceparent": s})
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("invalid traceparent '%s'", s)
+	}
+	return sc, nil
+}
+
+// Span is one timed operation in a trace. All methods are safe to call on a
+// nil Span, which is what Start returns when tracing is disabled.
+type Span struct {
+	span trace.Span
+}
+
+// SetAttribute records a key/value pair on the span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	var kv attribute.KeyValue
+	switch v := value.(type) {
+	case string:
+		kv = attribute.String(key, v)
+	case bool:
+		kv = attribute.Bool(key, v)
+	case int:
+		kv = attribute.Int(key, v)
+	case int64:
+		kv = attribute.Int64(key, v)
+	case float64:
+		kv = attribute.Float64(key, v)
+	default:
+		kv = attribute.String(key, fmt.Sprintf("%v", v))
+	}
+	s.span.SetAttributes(kv)
+}
+
+// RecordError marks the span as failed. A nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// Finish ends the span and hands it to the exporter. Later calls are
+// ignored.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.span.End()
+}
+
+// Start begins a span as a child of the span in ctx, if any, and returns a
+// context carrying the new span. The span is sampled if its parent is, and
+// root spans are always sampled.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	t := tracer()
+	if t == nil {
+		return ctx, nil
+	}
+	ctx, span := t.Start(ctx, name, trace.WithSpanKind(kind))
+	return ctx, &Span{span: span}
+}
+
+// WithRemoteParent returns a context whose next span continues the trace
+// described by a traceparent value received from a caller. Invalid values
+// are ignored.
+func WithRemoteParent(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+}
+
+// Traceparent returns the traceparent value to propagate from ctx, or ""
+// when there is no active trace.
+func Traceparent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier["traceparent"]
+}
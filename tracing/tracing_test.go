@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// memoryExporter keeps exported spans after shutdown, which the SDK's
+// in-memory exporter discards.
+type memoryExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+func (e memoryExporter) Shutdown(context.Context) error { return nil }
+
+func newMemoryExporter() memoryExporter {
+	return memoryExporter{tracetest.NewInMemoryExporter()}
+}
+
+func TestTraceparent(t *testing.T) {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(tp)
+	if err != nil {
+		t.Fatalf("ParseTraceparent failed: %v", err)
+	}
+	if !sc.IsSampled() || sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Unexpected span context for %s: %+v", tp, sc)
+	}
+
+	for _, bad := range []string{"", "00-abc-def-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"} {
+		if _, err := ParseTraceparent(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestStart_Disabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "noop", KindInternal)
+	if span != nil || Traceparent(ctx) != "" {
+		t.Errorf("Expected no span while tracing is disabled")
+	}
+	// Methods on a nil span must not panic
+	span.SetAttribute("k", "v")
+	span.RecordError(errors.New("boom"))
+	span.Finish()
+}
+
+func TestSpans(t *testing.T) {
+	exp := newMemoryExporter()
+	shutdown := SetExporter(exp, "test")
+
+	remote := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := WithRemoteParent(context.Background(), remote)
+	ctx, root := Start(ctx, "tools/call deploy", KindServer)
+	_, child := Start(ctx, "step build", KindInternal)
+	child.RecordError(errors.New("exit 1"))
+	child.Finish()
+	root.Finish()
+	shutdown()
+
+	spans := exp.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 exported spans, got %d", len(spans))
+	}
+	c, r := spans[0], spans[1]
+	if r.SpanContext.TraceID() != c.SpanContext.TraceID() || r.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected both spans in the remote trace")
+	}
+	if r.Parent.SpanID().String() != "00f067aa0ba902b7" || c.Parent.SpanID() != r.SpanContext.SpanID() {
+		t.Errorf("Unexpected parents: root %s, child %s", r.Parent.SpanID(), c.Parent.SpanID())
+	}
+	if c.Status.Code != codes.Error || c.Status.Description != "exit 1" || r.Status.Code == codes.Error {
+		t.Errorf("Expected only the child to fail")
+	}
+}
+
+func TestSpans_UnsampledParent(t *testing.T) {
+	exp := newMemoryExporter()
+	shutdown := SetExporter(exp, "test")
+
+	ctx := WithRemoteParent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx, span := Start(ctx, "tools/call deploy", KindServer)
+	tp := Traceparent(ctx)
+	span.Finish()
+	shutdown()
+
+	if len(tp) != 55 || tp[3:35] != "4bf92f3577b34da6a3ce929d0e0e4736" || tp[53:] != "00" {
+		t.Errorf("Expected an unsampled traceparent in the caller's trace, got %q", tp)
+	}
+	if spans := exp.GetSpans(); len(spans) != 0 {
+		t.Errorf("Expected no exported spans for an unsampled trace, got %d", len(spans))
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exp, err := NewWriterExporter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriterExporter failed: %v", err)
+	}
+	shutdown := SetExporter(exp, "devtool")
+	_, span := Start(context.Background(), "execute greet", KindInternal)
+	span.SetAttribute("tool.name", "greet")
+	span.SetAttribute("process.exit_code", 0)
+	span.Finish()
+	shutdown()
+
+	var got struct {
+		Name        string
+		SpanContext struct{ TraceID string }
+		Attributes  []struct {
+			Key   string
+			Value struct{ Value interface{} }
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Expected one JSON span, got %q: %v", buf.String(), err)
+	}
+	if got.Name != "execute greet" || len(got.SpanContext.TraceID) != 32 {
+		t.Fatalf("Unexpected span: %+v", got)
+	}
+	attrs := map[string]interface{}{}
+	for _, a := range got.Attributes {
+		attrs[a.Key] = a.Value.Value
+	}
+	if attrs["tool.name"] != "greet" || attrs["process.exit_code"] != float64(0) {
+		t.Errorf("Unexpected attributes: %v", attrs)
+	}
+}
+
+func TestOTLPURL(t *testing.T) {
+	for endpoint, want := range map[string]string{
+		"http://collector:4318":            "http://collector:4318/v1/traces",
+		"http://collector:4318/":           "http://collector:4318/v1/traces",
+		"http://collector:4318/v1/traces":  "http://collector:4318/v1/traces",
+		"http://collector:4318/v1/traces/": "http://collector:4318/v1/traces",
+	} {
+		if got := otlpURL(endpoint); got != want {
+			t.Errorf("otlpURL(%q) = %q, want %q", endpoint, got, want)
+		}
+	}
+}